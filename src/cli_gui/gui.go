@@ -1,7 +1,11 @@
 package cli_gui
 
 import (
+	"context"
 	"embed"
+	"errors"
+	"fmt"
+	"path/filepath"
 
 	"fyne.io/fyne/v2"
 	"fyne.io/fyne/v2/app"
@@ -9,12 +13,16 @@ import (
 	"fyne.io/fyne/v2/dialog"
 	"fyne.io/fyne/v2/layout"
 	"fyne.io/fyne/v2/widget"
+	"github.com/rifsxd/dvpl_go/cli_logic"
+	"github.com/rifsxd/dvpl_go/dvpl_logic"
+	"github.com/rifsxd/dvpl_go/engine"
 )
 
 //go:embed resource/dvpl_go.png
 var resources embed.FS
 
-func Gui() {
+// Gui opens the converter window with path filled in.
+func Gui(path string) {
 	myApp := app.NewWithID("com.rxd.dvpl_go")
 	myWindow := myApp.NewWindow("DVPL_GO GUI CONVERTER")
 
@@ -23,54 +31,102 @@ func Gui() {
 	iconResource := fyne.NewStaticResource("dvpl_go.png", iconData)
 	myWindow.SetIcon(iconResource)
 
-	config := &Config{}
+	options := engine.Options{Compress: dvpl_logic.DefaultCompressOptions}
+
+	progressBar := widget.NewProgressBar()
+	progressBar.Hide()
+	progressLabel := widget.NewLabel("")
+
+	var compressButton, decompressButton, cancelButton *widget.Button
+	var cancel context.CancelFunc
+
+	// startConversion runs the engine in the background so the window stays
+	// responsive and the Cancel button can stop it.
+	startConversion := func(mode string) {
+		var ctx context.Context
+		ctx, cancel = context.WithCancel(context.Background())
+		options.Mode = mode
+
+		compressButton.Disable()
+		decompressButton.Disable()
+		cancelButton.Enable()
+		progressBar.SetValue(0)
+		progressBar.Show()
+
+		go func() {
+			convertFiles(ctx, myWindow, path, options, progressBar, progressLabel) // Pass myWindow as a parameter
+
+			compressButton.Enable()
+			decompressButton.Enable()
+			cancelButton.Disable()
+			progressBar.Hide()
+			progressLabel.SetText("")
+		}()
+	}
 
-	/* Check if command-line arguments were provided
-	if len(os.Args) > 1 {
-		// Use the provided path as the initial path
-		config.Path = os.Args[1]
-	} else {
-		// Get the current working directory and set it as the initial path
-		initialPath, err := os.Getwd()
-		if err != nil {
-			// Handle the error, e.g., show a message to the user
-			initialPath = "" // Default to an empty string if there's an error
-		}
-		config.Path = initialPath
-	} */
+	compressButton = widget.NewButton("Compress", func() {
+		startConversion(engine.ModeCompress)
+	})
 
-	compressButton := widget.NewButton("Compress", func() {
-		config.Mode = "compress"
-		convertFiles(myWindow, config) // Pass myWindow as a parameter
+	decompressButton = widget.NewButton("Decompress", func() {
+		startConversion(engine.ModeDecompress)
 	})
 
-	decompressButton := widget.NewButton("Decompress", func() {
-		config.Mode = "decompress"
-		convertFiles(myWindow, config) // Pass myWindow as a parameter
+	cancelButton = widget.NewButton("Cancel", func() {
+		if cancel != nil {
+			cancel()
+		}
 	})
+	cancelButton.Disable()
 
 	keepOriginalsCheck := widget.NewCheck("Keep Originals", func(keep bool) {
-		config.KeepOriginals = keep
+		options.KeepOriginals = keep
 	})
 
+	preserveCheck := widget.NewCheck("Preserve Timestamps", func(preserve bool) {
+		options.NoPreserve = !preserve
+	})
+	preserveCheck.SetChecked(true)
+
+	incrementalCheck := widget.NewCheck("Incremental", func(incremental bool) {
+		options.Incremental = incremental
+	})
+
+	storeSelect := widget.NewSelect([]string{"auto", "always", "never"}, func(store string) {
+		options.Compress.Store, _ = dvpl_logic.ParseStorePolicy(store)
+	})
+	storeSelect.SetSelected("auto")
+
+	conflictSelect := widget.NewSelect([]string{"overwrite", "skip", "rename", "newer", "fail"}, func(policy string) {
+		options.OnConflict, _ = engine.ParseConflictPolicy(policy)
+	})
+	conflictSelect.SetSelected("overwrite")
+
 	pathEntry := widget.NewEntry()
-	pathEntry.SetText(config.Path)
+	pathEntry.SetText(path)
 	pathEntry.SetPlaceHolder("Enter directory or file path")
-	pathEntry.OnChanged = func(path string) {
-		config.Path = path
+	pathEntry.OnChanged = func(newPath string) {
+		path = newPath
 	}
 
-	// Create a custom success dialog
-	successDialog := dialog.NewCustom("Success", "OK", createSuccessContent(), myWindow)
-	successDialog.SetDismissText("OK")
+	outputEntry := widget.NewEntry()
+	outputEntry.SetPlaceHolder("Leave empty to write next to the sources")
+	outputEntry.OnChanged = func(outputDir string) {
+		options.OutputDir = outputDir
+	}
 
 	content := container.NewVBox(
-		widget.NewLabelWithStyle("DVPL_GO GUI CONVERTER • 4.2.0", fyne.TextAlignCenter, fyne.TextStyle{Bold: true}),
-		container.NewHBox(layout.NewSpacer(), compressButton, decompressButton, layout.NewSpacer()),
+		widget.NewLabelWithStyle("DVPL_GO GUI CONVERTER • "+cli_logic.Version, fyne.TextAlignCenter, fyne.TextStyle{Bold: true}),
+		container.NewHBox(layout.NewSpacer(), compressButton, decompressButton, cancelButton, layout.NewSpacer()),
 		widget.NewForm(
-			widget.NewFormItem("Options:", keepOriginalsCheck),
+			widget.NewFormItem("Options:", container.NewHBox(keepOriginalsCheck, preserveCheck, incrementalCheck)),
+			widget.NewFormItem("Store Raw:", storeSelect),
+			widget.NewFormItem("On Conflict:", conflictSelect),
 			widget.NewFormItem("Path:", pathEntry),
+			widget.NewFormItem("Output:", outputEntry),
 		),
+		progressBar,
+		progressLabel,
 	)
 
 	myWindow.SetContent(content)
@@ -78,28 +134,81 @@ func Gui() {
 	myWindow.ShowAndRun()
 }
 
-func showSuccessDialog(myWindow fyne.Window) {
-	successDialog := dialog.NewCustom("Success", "OK", createSuccessContent(), myWindow)
+func showSuccessDialog(myWindow fyne.Window, mode string, result *engine.Result) {
+	successDialog := dialog.NewCustom("Success", "OK", createSuccessContent(mode, result), myWindow)
 	successDialog.SetDismissText("OK")
 	successDialog.Show()
 }
 
-func createSuccessContent() fyne.CanvasObject {
+func createSuccessContent(mode string, result *engine.Result) fyne.CanvasObject {
 	successLabel := widget.NewLabelWithStyle("Conversion completed successfully", fyne.TextAlignCenter, fyne.TextStyle{Bold: true})
 
 	content := container.NewVBox(
 		successLabel,
 	)
 
+	if mode == engine.ModeCompress {
+		content.Add(widget.NewLabelWithStyle(fmt.Sprintf("%d of %d files stored uncompressed", result.StoredRaw, result.Converted), fyne.TextAlignCenter, fyne.TextStyle{}))
+	}
+
+	if mode == engine.ModeCompress && result.UpToDate > 0 {
+		content.Add(widget.NewLabelWithStyle(fmt.Sprintf("%d files rebuilt, %d up to date", result.Converted, result.UpToDate), fyne.TextAlignCenter, fyne.TextStyle{}))
+	}
+
+	if result.Skipped > 0 {
+		content.Add(widget.NewLabelWithStyle(fmt.Sprintf("%d files skipped, output already exists", result.Skipped), fyne.TextAlignCenter, fyne.TextStyle{}))
+	}
+
+	return content
+}
+
+// maxShownFailures is the number of failed files listed in the failure dialog.
+const maxShownFailures = 5
+
+func showFailureDialog(myWindow fyne.Window, result *engine.Result, failures []string) {
+	failureDialog := dialog.NewCustom("Finished with errors", "OK", createFailureContent(result, failures), myWindow)
+	failureDialog.Show()
+}
+
+func createFailureContent(result *engine.Result, failures []string) fyne.CanvasObject {
+	content := container.NewVBox(
+		widget.NewLabelWithStyle(fmt.Sprintf("%d files failed, %d converted", result.Failed, result.Converted), fyne.TextAlignCenter, fyne.TextStyle{Bold: true}),
+	)
+	for _, failure := range failures {
+		content.Add(widget.NewLabel(failure))
+	}
+	if result.Failed > len(failures) {
+		content.Add(widget.NewLabel(fmt.Sprintf("... and %d more", result.Failed-len(failures))))
+	}
+	if result.Skipped > 0 {
+		content.Add(widget.NewLabelWithStyle(fmt.Sprintf("%d files skipped, output already exists", result.Skipped), fyne.TextAlignCenter, fyne.TextStyle{}))
+	}
 	return content
 }
 
 // In your convertFiles function, call showSuccessDialog when the conversion is successful.
-func convertFiles(myWindow fyne.Window, config *Config) {
-	err := processFiles(config.Path, config)
-	if err != nil {
-		dialog.NewError(err, myWindow)
+func convertFiles(ctx context.Context, myWindow fyne.Window, path string, options engine.Options, progressBar *widget.ProgressBar, progressLabel *widget.Label) {
+	options.OnProgress = func(progress engine.Progress) {
+		if progress.Discovered > 0 {
+			progressBar.SetValue(float64(progress.Done) / float64(progress.Discovered))
+		}
+		progressLabel.SetText(fmt.Sprintf("%d / %d files • %s", progress.Done, progress.Discovered, filepath.Base(progress.Current)))
+	}
+	var failures []string
+	options.OnEvent = func(event engine.Event) {
+		if event.Kind == engine.EventFailed && len(failures) < maxShownFailures {
+			failures = append(failures, fmt.Sprintf("%s: %v", filepath.Base(event.Path), event.Err))
+		}
+	}
+
+	result, err := engine.New(options).Run(ctx, path)
+	if errors.Is(err, context.Canceled) {
+		dialog.ShowInformation("Cancelled", fmt.Sprintf("Conversion cancelled after %d files", result.Converted), myWindow)
+	} else if err != nil {
+		dialog.ShowError(err, myWindow)
+	} else if result.Failed > 0 {
+		showFailureDialog(myWindow, result, failures)
 	} else {
-		showSuccessDialog(myWindow) // Show the custom success dialog
+		showSuccessDialog(myWindow, options.Mode, result) // Show the custom success dialog
 	}
 }
@@ -0,0 +1,209 @@
+// Package cli_logic implements the command line front-end shared by the cli
+// and cli_gui builds.
+package cli_logic
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"os/signal"
+	"slices"
+
+	"github.com/fatih/color"
+	"github.com/rifsxd/dvpl_go/engine"
+)
+
+// ANSI escape codes for text coloring
+const (
+	RedColor    = "\033[31m"
+	GreenColor  = "\033[32m"
+	YellowColor = "\033[33m"
+	ResetColor  = "\033[0m"
+)
+
+// Config represents the configuration for the program.
+type Config struct {
+	Mode     string
+	Paths    []string // Directories or files to process.
+	Format   string   // Output format of the info mode.
+	Report   string   // File receiving the JSON run summary.
+	Manifest string   // Manifest file of the manifest and check-manifest modes.
+	Addr     string   // Listen address of the serve mode.
+	Version  bool     // Print the banner and exit.
+	Options  engine.Options
+
+	logger   *slog.Logger
+	progress *progressPrinter
+}
+
+// Info variables
+const Dev = "RifsxD"
+const Name = "DVPL_GO CLI CONVERTER"
+const Version = "4.2.0"
+const Repo = "https://github.com/RifsxD/dvpl_go"
+const Web = "https://rxd-mods.xyz"
+const Build = "27/10/2023"
+const Info = "A CLI Tool Coded In JavaScript To Convert WoTB ( Dava ) SmartDLC DVPL File Based On LZ4_HC Compression."
+
+// Cli runs the command line converter and exits with one of the Exit codes.
+// gui opens the graphical interface on the given path for the 'gui' mode and
+// is nil in builds without one, which then do not offer the mode.
+func Cli(gui func(path string)) {
+	os.Exit(run(gui))
+}
+
+func run(gui func(path string)) int {
+	if gui == nil {
+		commands = slices.DeleteFunc(commands, func(cmd command) bool { return cmd.name == "gui" })
+	}
+	config, err := parseCommandLine(os.Args[1:])
+	if errors.Is(err, errHelp) {
+		return ExitOK
+	}
+	if errors.As(err, &usageError{}) {
+		return ExitUsage
+	}
+	if err != nil {
+		logger, _ := newLogger(os.Stderr, "text", slog.LevelInfo, useColor(os.Stderr))
+		logger.Error("invalid command line", "err", err)
+		return ExitUsage
+	}
+	logger := config.logger
+
+	if config.Version {
+		printBanner()
+		return ExitOK
+	}
+
+	switch config.Mode {
+	case engine.ModeCompress, engine.ModeDecompress:
+		if usesPipe(config) {
+			return runPipe(config)
+		}
+		if config.Options.DryRun {
+			config.Options.Mode = config.Mode
+			return runDryRun(config)
+		}
+		return runConvert(config)
+	case engine.ModeVerify:
+		if usesPipe(config) {
+			logger.Error("invalid command line", "err", errPipeMode)
+			return ExitUsage
+		}
+		return runVerify(config)
+	case "info":
+		if usesPipe(config) {
+			logger.Error("invalid command line", "err", errPipeMode)
+			return ExitUsage
+		}
+		var infos []engine.FileInfo
+		for _, path := range config.Paths {
+			pathInfos, err := engine.Inspect(path)
+			if err != nil {
+				logger.Error("info failed", "err", err)
+				return ExitFailure
+			}
+			infos = append(infos, pathInfos...)
+		}
+		err := printInfo(os.Stdout, infos, config.Format)
+		if err != nil {
+			logger.Error("info failed", "err", err)
+			return ExitFailure
+		}
+	case "manifest", "check-manifest":
+		if len(config.Paths) != 1 || usesPipe(config) {
+			logger.Error("invalid command line", "err", config.Mode+" takes a single directory or file")
+			return ExitUsage
+		}
+		if config.Mode == "manifest" {
+			return runManifest(config)
+		}
+		return runCheckManifest(config)
+	case "serve":
+		if len(config.Paths) != 1 || usesPipe(config) {
+			logger.Error("invalid command line", "err", "serve takes a single directory")
+			return ExitUsage
+		}
+		return runServe(config)
+	case "gui":
+		gui(config.Paths[0])
+	}
+	return ExitOK
+}
+
+// printBanner prints the name and version of the program.
+func printBanner() {
+	cyan := color.New(color.FgCyan)
+
+	fmt.Println()
+	cyan.Println("• Name:", Name)
+	cyan.Println("• Version:", Version)
+	cyan.Println("• Build:", Build)
+	cyan.Println("• Dev:", Dev)
+	cyan.Println("• Repo:", Repo)
+	cyan.Println("• Web:", Web)
+	cyan.Println("• Info:", Info)
+	fmt.Println()
+}
+
+// runConvert compresses or decompresses config.Paths and returns the exit code.
+func runConvert(config *Config) int {
+	// Ctrl-C stops the conversion after the files in progress.
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+
+	logger := config.logger
+	summary, start := newRunSummary(config.Mode)
+	config.Options.Mode = config.Mode
+	config.Options.Logger = logger
+	config.Options.OnEvent = summary.record
+	config.Options.OnProgress = config.progress.update
+	result, err := engine.New(config.Options).RunPaths(ctx, config.Paths...)
+	config.progress.finish()
+	code := summary.finish(result, err, start)
+
+	switch summary.Status {
+	case "cancelled":
+		logger.Warn(config.Mode + " cancelled")
+	case "partial":
+		logger.Warn(config.Mode+" finished with failures", "failed", summary.Failed, "salvaged", summary.Salvaged, "not_removed", summary.NotRemoved)
+	case "failed":
+		if summary.Error != "" {
+			logger.Error(config.Mode+" failed", "err", summary.Error)
+		} else {
+			logger.Error(config.Mode+" failed", "failed", summary.Failed, "salvaged", summary.Salvaged)
+		}
+	default:
+		logger.Info(config.Mode + " finished")
+	}
+	if result != nil && config.Mode == engine.ModeCompress {
+		logger.Info("stored uncompressed", "files", result.StoredRaw, "converted", result.Converted)
+	}
+	if result != nil && config.Options.Incremental {
+		logger.Info("incremental", "rebuilt", result.Converted, "up_to_date", result.UpToDate)
+	}
+	if result != nil && result.Skipped > 0 {
+		logger.Info("skipped, output already exists", "files", result.Skipped)
+	}
+	if result != nil && result.Salvaged > 0 {
+		logger.Warn("salvaged damaged files", "files", result.Salvaged, "suffix", engine.PartialSuffix)
+	}
+	summary.print(logger)
+
+	return writeReport(config, summary, code)
+}
+
+// writeReport saves summary to the -report file, if any, and returns code, or
+// ExitFailure if the report cannot be written.
+func writeReport(config *Config, summary *runSummary, code int) int {
+	if config.Report == "" {
+		return code
+	}
+	if err := summary.write(config.Report); err != nil {
+		config.logger.Error("cannot write report", "path", config.Report, "err", err)
+		return ExitFailure
+	}
+	return code
+}
@@ -0,0 +1,448 @@
+package cli_logic
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"runtime"
+	"strings"
+
+	"github.com/fatih/color"
+	"github.com/rifsxd/dvpl_go/dvpl_logic"
+	"github.com/rifsxd/dvpl_go/engine"
+)
+
+// command is a subcommand of the command line converter.
+type command struct {
+	name    string
+	summary string
+	args    string // Usage of the positional arguments.
+
+	// flags registers the flags of the command on fs and returns the
+	// functions that check them and copy them into config once parsed.
+	flags func(fs *flag.FlagSet, config *Config) []func() error
+}
+
+var commands = []command{
+	{
+		name:    engine.ModeCompress,
+		summary: "Compress files into DVPL.",
+		args:    "[PATH...]",
+		flags: func(fs *flag.FlagSet, config *Config) []func() error {
+			fs.StringVar(&config.Format, "format", "table", "Output format of dry runs: 'table' / 'json'.")
+			fs.StringVar(&config.Report, "report", "", "Write a JSON summary of the run to this file.")
+			return join(walkFlags(fs, config), convertFlags(fs, config), compressFlags(fs, config), logFlags(fs, config))
+		},
+	},
+	{
+		name:    engine.ModeDecompress,
+		summary: "Decompress DVPL files into standard files.",
+		args:    "[PATH...]",
+		flags: func(fs *flag.FlagSet, config *Config) []func() error {
+			fs.StringVar(&config.Format, "format", "table", "Output format of dry runs: 'table' / 'json'.")
+			fs.StringVar(&config.Report, "report", "", "Write a JSON summary of the run to this file.")
+			fs.BoolVar(&config.Options.Decompress.Salvage, "salvage", false, "Recover what can be decoded from damaged files into '.partial' files with a '.partial.json' report.")
+			return join(walkFlags(fs, config), convertFlags(fs, config), decodeFlags(fs, config), logFlags(fs, config))
+		},
+	},
+	{
+		name:    engine.ModeVerify,
+		summary: "Check DVPL files without writing anything.",
+		args:    "[PATH...]",
+		flags: func(fs *flag.FlagSet, config *Config) []func() error {
+			fs.StringVar(&config.Format, "format", "table", "Output format: 'table' / 'json'.")
+			fs.StringVar(&config.Report, "report", "", "Write a JSON summary of the run to this file.")
+			return join(walkFlags(fs, config), decodeFlags(fs, config), logFlags(fs, config))
+		},
+	},
+	{
+		name:    "info",
+		summary: "Print the footer details of DVPL files and check their CRC32.",
+		args:    "[PATH...]",
+		flags: func(fs *flag.FlagSet, config *Config) []func() error {
+			fs.StringVar(&config.Format, "format", "table", "Output format: 'table' / 'json' / 'csv'.")
+			return logFlags(fs, config)
+		},
+	},
+	{
+		name:    "manifest",
+		summary: "Write the checksums of every file of a tree to a manifest.",
+		args:    "[PATH]",
+		flags: func(fs *flag.FlagSet, config *Config) []func() error {
+			fs.StringVar(&config.Format, "format", "json", "Manifest format: 'json' / 'sha256' (sha256sum compatible, naming DVPL files as decompressed).")
+			fs.StringVar(&config.Manifest, "manifest", "", "Write the manifest to this file instead of standard output.")
+			filterFlags(fs, config)
+			return logFlags(fs, config)
+		},
+	},
+	{
+		name:    "check-manifest",
+		summary: "Check a tree against a JSON manifest.",
+		args:    "[PATH]",
+		flags: func(fs *flag.FlagSet, config *Config) []func() error {
+			fs.StringVar(&config.Format, "format", "table", "Output format: 'table' / 'json'.")
+			fs.StringVar(&config.Manifest, "manifest", "", "Manifest written by the manifest command, or '-' for standard input. Required.")
+			filterFlags(fs, config)
+			return logFlags(fs, config)
+		},
+	},
+	{
+		name:    "serve",
+		summary: "Serve a DVPL tree decompressed over HTTP, with its footer details under /api/info.",
+		args:    "[PATH]",
+		flags: func(fs *flag.FlagSet, config *Config) []func() error {
+			fs.StringVar(&config.Addr, "addr", ":8080", "Address to listen on.")
+			path := fs.String("path", "", "Directory to serve, instead of PATH.")
+			return append(logFlags(fs, config), func() error {
+				if *path != "" {
+					config.Paths = []string{*path}
+				}
+				return nil
+			})
+		},
+	},
+	{
+		name:    "gui",
+		summary: "Open the graphical user interface window.",
+		args:    "[PATH]",
+		flags:   logFlags,
+	},
+}
+
+// errHelp is returned by parseCommandLine once help has been printed.
+var errHelp = errors.New("help requested")
+
+// usageError is a command line error whose message has already been printed
+// together with the usage.
+type usageError struct{ err error }
+
+func (e usageError) Error() string { return e.err.Error() }
+
+// parseCommandLine parses the arguments following the program name. They are
+// either a command with its flags and paths, or the flags of the -mode style
+// command line kept for existing scripts.
+func parseCommandLine(args []string) (*Config, error) {
+	if len(args) == 0 {
+		printUsage(os.Stderr)
+		return nil, usageError{errors.New("no command given")}
+	}
+	if strings.HasPrefix(args[0], "-") {
+		return parseModeFlags(args)
+	}
+
+	name := args[0]
+	if name == "help" {
+		return nil, printHelp(args[1:])
+	}
+	cmd := findCommand(name)
+	if cmd == nil {
+		return nil, unknownCommand(name)
+	}
+
+	config := &Config{Mode: cmd.name}
+	fs := flag.NewFlagSet("dvpl_go "+cmd.name, flag.ContinueOnError)
+	fs.Usage = func() { printCommandUsage(fs.Output(), cmd, fs) }
+	checks := cmd.flags(fs, config)
+	paths, err := parseInterspersed(fs, args[1:])
+	if err != nil {
+		return nil, parseError(err)
+	}
+
+	config.Paths = paths
+	if len(config.Paths) == 0 {
+		config.Paths = []string{"."}
+	}
+	return config, runChecks(checks)
+}
+
+// parseInterspersed parses the flags in args, which may follow the paths as
+// in "decompress src -keep-originals", and returns the paths. Arguments after
+// "--" are always paths.
+func parseInterspersed(fs *flag.FlagSet, args []string) ([]string, error) {
+	var paths []string
+	for {
+		if err := fs.Parse(args); err != nil {
+			return nil, err
+		}
+		rest := fs.Args()
+		if len(rest) == 0 {
+			return paths, nil
+		}
+		if parsed := len(args) - len(rest); parsed > 0 && args[parsed-1] == "--" {
+			return append(paths, rest...), nil
+		}
+		paths = append(paths, rest[0])
+		args = rest[1:]
+	}
+}
+
+// parseModeFlags parses the -mode style command line, where every flag of
+// every command is accepted and the paths to process are given by -path or,
+// as with commands, as arguments.
+func parseModeFlags(args []string) (*Config, error) {
+	config := &Config{}
+	fs := flag.NewFlagSet("dvpl_go", flag.ContinueOnError)
+	fs.Usage = func() { printUsage(fs.Output()) }
+	modes := make([]string, 0, len(commands)+1)
+	for _, cmd := range commands {
+		modes = append(modes, "'"+cmd.name+"'")
+	}
+	fs.StringVar(&config.Mode, "mode", "", "Mode can be "+strings.Join(append(modes, "'help'"), " / ")+".")
+	path := fs.String("path", ".", "Directory or file to process.")
+	fs.StringVar(&config.Format, "format", "table", "Output format of the info mode: 'table' / 'json' / 'csv', of the verify and check-manifest modes and dry runs: 'table' / 'json', or of the manifest mode: 'json' / 'sha256'.")
+	fs.StringVar(&config.Report, "report", "", "Write a JSON summary of the compress/decompress/verify run to this file.")
+	fs.BoolVar(&config.Version, "version", false, "Print the version banner and exit.")
+	fs.StringVar(&config.Manifest, "manifest", "", "Manifest file written by the manifest mode or read by the check-manifest mode.")
+	fs.StringVar(&config.Addr, "addr", ":8080", "Address the serve mode listens on.")
+	fs.BoolVar(&config.Options.Decompress.Salvage, "salvage", false, "In decompress mode, recover what can be decoded from damaged files into '.partial' files with a '.partial.json' report.")
+	checks := join(walkFlags(fs, config), convertFlags(fs, config), compressFlags(fs, config), decodeFlags(fs, config), logFlags(fs, config))
+	paths, err := parseInterspersed(fs, args)
+	if err != nil {
+		return nil, parseError(err)
+	}
+	config.Paths = []string{*path}
+	if len(paths) > 0 {
+		if flagSet(fs, "path") {
+			return nil, fmt.Errorf("paths %q cannot be combined with -path", paths)
+		}
+		config.Paths = paths
+	}
+
+	if err := runChecks(checks); err != nil || config.Version {
+		return config, err
+	}
+
+	switch config.Mode {
+	case "":
+		return nil, errors.New("No mode selected. Use 'dvpl_go help' for usage information.")
+	case "help":
+		printUsage(os.Stdout)
+		return nil, errHelp
+	}
+	if findCommand(config.Mode) == nil {
+		return nil, fmt.Errorf("unknown mode %q", config.Mode)
+	}
+	if config.Options.Incremental && config.Mode != engine.ModeCompress {
+		return nil, errors.New("-incremental is only supported in compress mode.")
+	}
+	if config.Options.Decompress.Salvage && config.Mode != engine.ModeDecompress {
+		return nil, errors.New("-salvage is only supported in decompress mode.")
+	}
+	return config, nil
+}
+
+// flagSet reports whether the flag name was given on the command line.
+func flagSet(fs *flag.FlagSet, name string) bool {
+	set := false
+	fs.Visit(func(f *flag.Flag) {
+		if f.Name == name {
+			set = true
+		}
+	})
+	return set
+}
+
+func findCommand(name string) *command {
+	for i := range commands {
+		if commands[i].name == name {
+			return &commands[i]
+		}
+	}
+	return nil
+}
+
+// unknownCommand prints the list of commands for a mistyped command name.
+func unknownCommand(name string) error {
+	err := fmt.Errorf("unknown command %q", name)
+	fmt.Fprintf(os.Stderr, "%v\n", err)
+	printUsage(os.Stderr)
+	return usageError{err}
+}
+
+// parseError turns a flag parsing error, already printed with the usage by
+// the flag package, into errHelp or a usageError.
+func parseError(err error) error {
+	if errors.Is(err, flag.ErrHelp) {
+		return errHelp
+	}
+	return usageError{err}
+}
+
+func join(groups ...[]func() error) []func() error {
+	var checks []func() error
+	for _, group := range groups {
+		checks = append(checks, group...)
+	}
+	return checks
+}
+
+func runChecks(checks []func() error) error {
+	for _, check := range checks {
+		if err := check(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// logFlags registers the logging flags shared by every command.
+func logFlags(fs *flag.FlagSet, config *Config) []func() error {
+	quiet := fs.Bool("quiet", false, "Only log warnings and errors.")
+	verbose := fs.Bool("verbose", false, "Also log debug messages, such as ignored files.")
+	logFormat := fs.String("log-format", "text", "Log format: 'text' / 'json'. Logs are written to stderr.")
+
+	return []func() error{func() error {
+		if *quiet && *verbose {
+			return errors.New("-quiet and -verbose cannot be combined.")
+		}
+		level := slog.LevelInfo
+		if *quiet {
+			level = slog.LevelWarn
+		} else if *verbose {
+			level = slog.LevelDebug
+		}
+
+		// The progress bar is only drawn on a terminal, between text log lines.
+		config.progress = newProgressPrinter(!*quiet && *logFormat == "text" && isTerminal(os.Stderr))
+		var err error
+		config.logger, err = newLogger(config.progress, *logFormat, level, useColor(os.Stderr))
+		color.NoColor = !useColor(os.Stdout)
+		return err
+	}}
+}
+
+// walkFlags registers the flags selecting and scheduling the files of a walk.
+func walkFlags(fs *flag.FlagSet, config *Config) []func() error {
+	filterFlags(fs, config)
+	fs.IntVar(&config.Options.Jobs, "jobs", runtime.NumCPU(), "Number of files to process concurrently.")
+	memory := fs.Int64("memory", engine.DefaultMemoryBudget>>20, "Memory budget in MiB for file data held at once.")
+
+	return []func() error{func() error {
+		config.Options.MemoryBudget = *memory << 20
+		return nil
+	}}
+}
+
+// filterFlags registers the flags selecting the files of a walk.
+func filterFlags(fs *flag.FlagSet, config *Config) {
+	fs.Var((*stringList)(&config.Options.Include), "include", "Only process files matching this glob ('**' matches any directories). Can be repeated.")
+	fs.Var((*stringList)(&config.Options.Exclude), "exclude", "Skip files and directories matching this glob ('**' matches any directories). Can be repeated.")
+	fs.BoolVar(&config.Options.IncludeHidden, "include-hidden", false, "Also process hidden and VCS directories such as .git.")
+}
+
+// convertFlags registers the flags shared by compress and decompress.
+func convertFlags(fs *flag.FlagSet, config *Config) []func() error {
+	fs.BoolVar(&config.Options.KeepOriginals, "keep-originals", false, "Keep the original files after conversion.")
+	fs.BoolVar(&config.Options.NoPreserve, "no-preserve", false, "Do not copy permissions and timestamps of the source files to the outputs.")
+	fs.StringVar(&config.Options.OutputDir, "out", "", "Directory receiving the converted files in a mirrored tree, or '-' for standard output. The input is left untouched.")
+	onConflict := fs.String("on-conflict", "overwrite", "What to do when an output file exists: 'overwrite' / 'skip' / 'rename' / 'newer' (overwrite if the source is newer) / 'fail' (abort before writing anything).")
+	fs.BoolVar(&config.Options.DryRun, "dry-run", false, "Print what would be done without writing anything.")
+
+	return []func() error{func() error {
+		var err error
+		config.Options.OnConflict, err = engine.ParseConflictPolicy(*onConflict)
+		return err
+	}}
+}
+
+// compressFlags registers the flags of compress only.
+func compressFlags(fs *flag.FlagSet, config *Config) []func() error {
+	fs.IntVar(&config.Options.Compress.Level, "level", dvpl_logic.DefaultLevel, "LZ4-HC compression level from 1 to 9.")
+	compressionType := fs.String("type", "lz4hc", "Compression type: 'lz4hc' (footer type 2) / 'lz4' (footer type 1).")
+	store := fs.String("store", "auto", "Store files uncompressed (footer type 0): 'auto' (when compression does not help) / 'always' / 'never'.")
+	fs.BoolVar(&config.Options.Incremental, "incremental", false, "Skip files whose .dvpl output is up to date.")
+
+	return []func() error{func() error {
+		if level := config.Options.Compress.Level; level < 1 || level > 9 {
+			return fmt.Errorf("-level must be between 1 and 9, got %d.", level)
+		}
+		var err error
+		config.Options.Compress.Type, err = dvpl_logic.ParseType(*compressionType)
+		if err != nil {
+			return err
+		}
+		if config.Options.Compress.Type != dvpl_logic.TypeLZ4HC && flagSet(fs, "level") {
+			return errors.New("-level only applies to -type lz4hc.")
+		}
+		config.Options.Compress.Store, err = dvpl_logic.ParseStorePolicy(*store)
+		return err
+	}}
+}
+
+// decodeFlags registers the flags of commands reading DVPL files.
+func decodeFlags(fs *flag.FlagSet, config *Config) []func() error {
+	maxOutputSize := fs.Int64("max-output-size", dvpl_logic.DefaultMaxOutputSize>>20, "Largest decompressed size in MiB accepted from a DVPL footer.")
+
+	return []func() error{func() error {
+		if *maxOutputSize <= 0 {
+			return errors.New("-max-output-size must be positive.")
+		}
+		config.Options.Decompress.MaxOutputSize = *maxOutputSize << 20
+		return nil
+	}}
+}
+
+// printUsage prints the list of commands.
+func printUsage(w io.Writer) {
+	fmt.Fprintf(w, "Usage: dvpl_go COMMAND [FLAGS] [PATH...]\n\nCommands:\n")
+	for _, cmd := range commands {
+		fmt.Fprintf(w, "  %-16s %s\n", cmd.name, cmd.summary)
+	}
+	fmt.Fprintf(w, "  %-16s %s\n", "help", "Show the help of a command.")
+	fmt.Fprintf(w, `
+Paths default to the current directory. Flags may also follow the paths, and
+arguments after '--' are always paths. A .dvplignore file in any directory
+lists paths to skip, using the .gitignore syntax. A path of '-' makes compress
+and decompress read standard input and write standard output, and '-out -'
+writes a single converted file to standard output.
+
+Run 'dvpl_go help COMMAND' for the flags of a command, and 'dvpl_go -version'
+for the version. The older 'dvpl_go -mode MODE [-path PATH | PATH...]' form
+still works.
+
+Exit codes:
+  %d  every file was processed
+  %d  nothing was processed, or the run could not start
+  %d  the command line is invalid
+  %d  some files were processed and some failed
+`, ExitOK, ExitFailure, ExitUsage, ExitPartial)
+}
+
+// printHelp prints the help of the command named in args, or the list of
+// commands.
+func printHelp(args []string) error {
+	if len(args) == 0 {
+		printUsage(os.Stdout)
+		return errHelp
+	}
+	cmd := findCommand(args[0])
+	if cmd == nil {
+		return unknownCommand(args[0])
+	}
+	fs := flag.NewFlagSet("dvpl_go "+cmd.name, flag.ContinueOnError)
+	cmd.flags(fs, &Config{})
+	printCommandUsage(os.Stdout, cmd, fs)
+	return errHelp
+}
+
+// printCommandUsage prints the usage of cmd, generated from its flags.
+func printCommandUsage(w io.Writer, cmd *command, fs *flag.FlagSet) {
+	fmt.Fprintf(w, "Usage: dvpl_go %s [FLAGS] %s\n\n%s\n\nFlags:\n", cmd.name, cmd.args, cmd.summary)
+	fs.SetOutput(w)
+	fs.PrintDefaults()
+}
+
+// stringList is a flag that can be given several times.
+type stringList []string
+
+func (l *stringList) String() string {
+	return strings.Join(*l, ",")
+}
+
+func (l *stringList) Set(value string) error {
+	*l = append(*l, value)
+	return nil
+}
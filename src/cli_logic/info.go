@@ -0,0 +1,48 @@
+package cli_logic
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strconv"
+	"text/tabwriter"
+
+	"github.com/rifsxd/dvpl_go/engine"
+)
+
+// printInfo writes infos to w in the given format: "table", "json" or "csv".
+func printInfo(w io.Writer, infos []engine.FileInfo, format string) error {
+	switch format {
+	case "", "table":
+		tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(tw, "PATH\tORIGINAL\tCOMPRESSED\tRATIO\tCRC32\tTYPE\tCRC OK\tERROR")
+		for _, info := range infos {
+			fmt.Fprintf(tw, "%s\t%d\t%d\t%.2f\t%08x\t%s\t%t\t%s\n",
+				info.Path, info.OriginalSize, info.CompressedSize, info.Ratio, info.CRC32, info.Type, info.CRCValid, info.Error)
+		}
+		return tw.Flush()
+	case "json":
+		encoder := json.NewEncoder(w)
+		encoder.SetIndent("", "  ")
+		return encoder.Encode(infos)
+	case "csv":
+		cw := csv.NewWriter(w)
+		cw.Write([]string{"path", "original_size", "compressed_size", "ratio", "crc32", "type", "crc_valid", "error"})
+		for _, info := range infos {
+			cw.Write([]string{
+				info.Path,
+				strconv.FormatUint(uint64(info.OriginalSize), 10),
+				strconv.FormatUint(uint64(info.CompressedSize), 10),
+				strconv.FormatFloat(info.Ratio, 'f', 4, 64),
+				fmt.Sprintf("%08x", info.CRC32),
+				info.Type,
+				strconv.FormatBool(info.CRCValid),
+				info.Error,
+			})
+		}
+		cw.Flush()
+		return cw.Error()
+	}
+	return fmt.Errorf("unknown output format %q", format)
+}
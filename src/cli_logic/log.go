@@ -0,0 +1,146 @@
+package cli_logic
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/mattn/go-isatty"
+)
+
+// isTerminal reports whether f is a terminal.
+func isTerminal(f *os.File) bool {
+	return isatty.IsTerminal(f.Fd()) || isatty.IsCygwinTerminal(f.Fd())
+}
+
+// useColor reports whether ANSI colours may be written to f: NO_COLOR must be
+// unset or empty and f must be a terminal.
+func useColor(f *os.File) bool {
+	return os.Getenv("NO_COLOR") == "" && isTerminal(f)
+}
+
+// paint wraps s in the ANSI colour code if enabled.
+func paint(enabled bool, code, s string) string {
+	if !enabled {
+		return s
+	}
+	return code + s + ResetColor
+}
+
+// newLogger returns a logger writing to w in format "text" or "json" and
+// dropping records below level.
+func newLogger(w io.Writer, format string, level slog.Level, color bool) (*slog.Logger, error) {
+	switch format {
+	case "text":
+		return slog.New(&consoleHandler{mu: &sync.Mutex{}, w: w, level: level, color: color}), nil
+	case "json":
+		return slog.New(slog.NewJSONHandler(w, &slog.HandlerOptions{Level: level})), nil
+	}
+	return nil, fmt.Errorf("unknown log format %q", format)
+}
+
+// consoleHandler writes records as single human readable lines:
+//
+//	ERROR conversion failed path=a.xml.dvpl op=convert err="..."
+type consoleHandler struct {
+	mu     *sync.Mutex
+	w      io.Writer
+	level  slog.Level
+	color  bool
+	prefix string // Group prefix of the attributes.
+	attrs  string // Attributes added with WithAttrs, already formatted.
+}
+
+func (h *consoleHandler) Enabled(_ context.Context, level slog.Level) bool {
+	return level >= h.level
+}
+
+func (h *consoleHandler) Handle(_ context.Context, record slog.Record) error {
+	var line strings.Builder
+	line.WriteString(h.levelTag(record.Level))
+	line.WriteByte(' ')
+	line.WriteString(record.Message)
+	line.WriteString(h.attrs)
+	record.Attrs(func(attr slog.Attr) bool {
+		appendAttr(&line, h.prefix, attr)
+		return true
+	})
+	line.WriteByte('\n')
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	_, err := io.WriteString(h.w, line.String())
+	return err
+}
+
+func (h *consoleHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	clone := *h
+	var line strings.Builder
+	for _, attr := range attrs {
+		appendAttr(&line, h.prefix, attr)
+	}
+	clone.attrs += line.String()
+	return &clone
+}
+
+func (h *consoleHandler) WithGroup(name string) slog.Handler {
+	if name == "" {
+		return h
+	}
+	clone := *h
+	clone.prefix += name + "."
+	return &clone
+}
+
+// levelTag returns the coloured name of level.
+func (h *consoleHandler) levelTag(level slog.Level) string {
+	switch {
+	case level >= slog.LevelError:
+		return paint(h.color, RedColor, "ERROR")
+	case level >= slog.LevelWarn:
+		return paint(h.color, YellowColor, "WARN")
+	case level >= slog.LevelInfo:
+		return paint(h.color, GreenColor, "INFO")
+	}
+	return "DEBUG"
+}
+
+// appendAttr writes attr as " key=value", quoting values that need it.
+func appendAttr(line *strings.Builder, prefix string, attr slog.Attr) {
+	attr.Value = attr.Value.Resolve()
+	if attr.Equal(slog.Attr{}) {
+		return
+	}
+	if attr.Value.Kind() == slog.KindGroup {
+		if attr.Key != "" {
+			prefix += attr.Key + "."
+		}
+		for _, member := range attr.Value.Group() {
+			appendAttr(line, prefix, member)
+		}
+		return
+	}
+
+	var value string
+	switch attr.Value.Kind() {
+	case slog.KindDuration:
+		value = attr.Value.Duration().Round(time.Millisecond).String()
+	default:
+		value = attr.Value.String()
+	}
+	if value == "" || strings.ContainsAny(value, " \"=\t\n") {
+		value = strconv.Quote(value)
+	}
+
+	line.WriteByte(' ')
+	line.WriteString(prefix)
+	line.WriteString(attr.Key)
+	line.WriteByte('=')
+	line.WriteString(value)
+}
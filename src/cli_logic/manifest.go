@@ -0,0 +1,141 @@
+package cli_logic
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+
+	"github.com/rifsxd/dvpl_go/engine"
+)
+
+// runManifest writes the manifest of config.Paths[0] and returns the exit code.
+func runManifest(config *Config) int {
+	logger := config.logger
+	if config.Format != "json" && config.Format != "sha256" && config.Format != "table" {
+		logger.Error("invalid command line", "err", fmt.Sprintf("unknown manifest format %q", config.Format))
+		return ExitUsage
+	}
+
+	manifest, err := engine.BuildManifest(config.Paths[0], manifestOptions(config, os.Stdout))
+	if err != nil {
+		logger.Error("manifest failed", "err", err)
+		return ExitFailure
+	}
+
+	name, w := stdio, io.Writer(os.Stdout)
+	if config.Manifest != "" && config.Manifest != stdio {
+		name = config.Manifest
+		file, err := os.Create(name)
+		if err != nil {
+			logger.Error("manifest failed", "err", err)
+			return ExitFailure
+		}
+		defer file.Close()
+		w = file
+	}
+
+	// The -mode command line defaults -format to table, which means JSON here.
+	if config.Format == "sha256" {
+		err = manifest.WriteSHA256Sums(w)
+	} else {
+		encoder := json.NewEncoder(w)
+		encoder.SetIndent("", "  ")
+		err = encoder.Encode(manifest)
+	}
+	if err != nil {
+		logger.Error("manifest failed", "err", err)
+		return ExitFailure
+	}
+	logger.Info("manifest written", "files", len(manifest.Files), "output", name)
+	return ExitOK
+}
+
+// runCheckManifest checks config.Paths[0] against the manifest named by
+// config.Manifest and returns the exit code.
+func runCheckManifest(config *Config) int {
+	logger := config.logger
+	if config.Manifest == "" {
+		logger.Error("invalid command line", "err", "-manifest is required")
+		return ExitUsage
+	}
+
+	input := os.Stdin
+	if config.Manifest != stdio {
+		file, err := os.Open(config.Manifest)
+		if err != nil {
+			logger.Error("check-manifest failed", "err", err)
+			return ExitFailure
+		}
+		defer file.Close()
+		input = file
+	}
+	manifest, err := engine.ReadManifest(input)
+	if err != nil {
+		logger.Error("check-manifest failed", "path", config.Manifest, "err", err)
+		return ExitFailure
+	}
+
+	check, err := engine.CheckManifest(config.Paths[0], manifest, manifestOptions(config, os.Stdin))
+	if err != nil {
+		logger.Error("check-manifest failed", "err", err)
+		return ExitFailure
+	}
+
+	if config.Format == "json" {
+		encoder := json.NewEncoder(os.Stdout)
+		encoder.SetIndent("", "  ")
+		encoder.Encode(check)
+	} else {
+		printManifestCheck(check, useColor(os.Stdout))
+	}
+
+	if !check.OK() {
+		logger.Error("check-manifest failed", "checked", check.Checked, "added", len(check.Added),
+			"missing", len(check.Missing), "modified", len(check.Modified), "corrupted", len(check.Corrupted))
+		return ExitFailure
+	}
+	logger.Info("check-manifest finished", "valid", check.Checked)
+	return ExitOK
+}
+
+// manifestOptions selects the files of a manifest for config, leaving out the
+// manifest itself, whether it is named by -manifest or is the regular file
+// the standard stream redirected is attached to.
+func manifestOptions(config *Config, redirected *os.File) engine.ManifestOptions {
+	options := engine.ManifestOptions{
+		Include:       config.Options.Include,
+		Exclude:       config.Options.Exclude,
+		IncludeHidden: config.Options.IncludeHidden,
+	}
+	if config.Manifest != "" && config.Manifest != stdio {
+		options.Skip = []string{config.Manifest}
+	} else if info, err := redirected.Stat(); err == nil && info.Mode().IsRegular() {
+		options.SkipFiles = []fs.FileInfo{info}
+	}
+	return options
+}
+
+// printManifestCheck lists the differences found by a manifest check.
+func printManifestCheck(check *engine.ManifestCheck, color bool) {
+	printPaths := func(title, code string, paths []string) {
+		if len(paths) == 0 {
+			return
+		}
+		fmt.Printf("\n%s (%d):\n", title, len(paths))
+		for _, path := range paths {
+			fmt.Printf("  %s\n", paint(color, code, path))
+		}
+	}
+	printPaths("Added", YellowColor, check.Added)
+	printPaths("Missing", RedColor, check.Missing)
+	printPaths("Modified", RedColor, check.Modified)
+
+	if len(check.Corrupted) > 0 {
+		fmt.Printf("\nCorrupted (%d):\n", len(check.Corrupted))
+		for _, problem := range check.Corrupted {
+			fmt.Printf("  %s: %s\n", paint(color, RedColor, problem.Path), problem.Error)
+		}
+	}
+}
@@ -0,0 +1,122 @@
+package cli_logic
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"os"
+
+	"github.com/rifsxd/dvpl_go/dvpl_logic"
+	"github.com/rifsxd/dvpl_go/engine"
+)
+
+// stdio is the path naming standard input, or standard output for -out.
+const stdio = "-"
+
+// errPipeMode is reported when '-' is given to a command that cannot stream.
+var errPipeMode = errors.New("'-' is only supported by compress and decompress")
+
+// usesPipe reports whether config reads from standard input or writes to
+// standard output.
+func usesPipe(config *Config) bool {
+	if config.Options.OutputDir == stdio {
+		return true
+	}
+	for _, path := range config.Paths {
+		if path == stdio {
+			return true
+		}
+	}
+	return false
+}
+
+// runPipe compresses or decompresses a single stream: standard input, or the
+// file given as the only path, to standard output. It returns the exit code.
+func runPipe(config *Config) int {
+	logger := config.logger
+	if len(config.Paths) != 1 || config.Options.DryRun {
+		logger.Error("'-' takes a single input and cannot be combined with -dry-run")
+		return ExitUsage
+	}
+	if config.Options.OutputDir != "" && config.Options.OutputDir != stdio {
+		logger.Error("standard input is always written to standard output, -out must be '-' or omitted")
+		return ExitUsage
+	}
+
+	name := config.Paths[0]
+	input := os.Stdin
+	if name != stdio {
+		file, err := os.Open(name)
+		if err != nil {
+			logger.Error("cannot read file", "path", name, "err", err)
+			return ExitFailure
+		}
+		defer file.Close()
+		input = file
+	}
+
+	var bytesIn, bytesOut int64
+	var err error
+	if config.Mode == engine.ModeCompress {
+		bytesIn, bytesOut, err = compressStream(os.Stdout, input, config.Options.Compress)
+	} else {
+		bytesIn, bytesOut, err = decompressStream(os.Stdout, input, config.Options.Decompress)
+	}
+	if err != nil {
+		logger.Error(config.Mode+" failed", "path", name, "err", err)
+		return ExitFailure
+	}
+	logger.Debug(config.Mode+"ed", "path", name, "bytes_in", bytesIn, "bytes_out", bytesOut)
+	return ExitOK
+}
+
+// compressStream writes r as a DVPL file to w.
+func compressStream(w io.Writer, r io.Reader, options dvpl_logic.CompressOptions) (int64, int64, error) {
+	counter := &countingWriter{w: w}
+	writer := dvpl_logic.NewWriterWithOptions(counter, options)
+	n, err := io.Copy(writer, r)
+	if err != nil {
+		return n, counter.n, err
+	}
+	err = writer.Close()
+	return n, counter.n, err
+}
+
+// decompressStream writes the contents of the DVPL file read from r to w. The
+// footer sits at the end of the file, so r is read completely first. With
+// options.Salvage, whatever can be recovered from a damaged file is written
+// before its *dvpl_logic.SalvageError is returned.
+func decompressStream(w io.Writer, r io.Reader, options dvpl_logic.DecompressOptions) (int64, int64, error) {
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return int64(len(data)), 0, err
+	}
+
+	if options.Salvage {
+		decoded, err := dvpl_logic.DecompressDVPLWithOptions(data, options)
+		n, writeErr := w.Write(decoded)
+		if err == nil {
+			err = writeErr
+		}
+		return int64(len(data)), int64(n), err
+	}
+
+	reader, err := dvpl_logic.NewReaderWithOptions(bytes.NewReader(data), int64(len(data)), options)
+	if err != nil {
+		return int64(len(data)), 0, err
+	}
+	n, err := io.Copy(w, reader)
+	return int64(len(data)), n, err
+}
+
+// countingWriter counts the bytes written through it.
+type countingWriter struct {
+	w io.Writer
+	n int64
+}
+
+func (c *countingWriter) Write(p []byte) (int, error) {
+	n, err := c.w.Write(p)
+	c.n += int64(n)
+	return n, err
+}
@@ -0,0 +1,120 @@
+package cli_logic
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/rifsxd/dvpl_go/engine"
+)
+
+// plannedAction is one line of the dry-run report.
+type plannedAction struct {
+	Action         string `json:"action"`
+	Path           string `json:"path"`
+	Size           int64  `json:"size"`
+	Output         string `json:"output,omitempty"`
+	OutputSize     int64  `json:"outputSize,omitempty"` // Unknown (0) for compression.
+	Overwrite      bool   `json:"overwrite,omitempty"`
+	DeleteOriginal bool   `json:"deleteOriginal,omitempty"`
+	Error          string `json:"error,omitempty"`
+}
+
+// runDryRun prints what a conversion with config would do without reading
+// payloads or writing anything, and returns the exit code the conversion
+// would have: planned failures, such as conflicts under -on-conflict fail or
+// damaged footers, fail the dry run too.
+func runDryRun(config *Config) int {
+	logger := config.logger
+	summary, start := newRunSummary(config.Mode)
+	summary.DryRun = true
+
+	var actions []plannedAction
+	config.Options.DryRun = true
+	config.Options.Logger = logger
+	config.Options.OnEvent = func(event engine.Event) {
+		summary.record(event)
+		action := plannedAction{Path: event.Path}
+		switch event.Kind {
+		case engine.EventPlanned:
+			action.Action = config.Options.Mode
+			action.Size = event.BytesIn
+			action.Output = event.Output
+			action.OutputSize = event.BytesOut
+			action.Overwrite = event.Overwrite
+			action.DeleteOriginal = event.RemoveOriginal
+		case engine.EventIgnored:
+			action.Action = "ignore"
+		case engine.EventSkipped:
+			action.Action = "skip"
+			action.Output = event.Output
+		case engine.EventUpToDate:
+			action.Action = "up-to-date"
+			action.Output = event.Output
+		case engine.EventFailed:
+			action.Action = "fail"
+			action.Error = event.Err.Error()
+		default:
+			return
+		}
+		actions = append(actions, action)
+	}
+
+	result, err := engine.New(config.Options).RunPaths(context.Background(), config.Paths...)
+	if result != nil {
+		if printErr := printPlan(config, actions, result); printErr != nil {
+			logger.Error("dry run failed", "err", printErr)
+			return ExitFailure
+		}
+	}
+
+	code := summary.finish(result, err, start)
+	if summary.Error != "" {
+		logger.Error("dry run failed", "err", summary.Error)
+	} else if summary.Failed > 0 {
+		logger.Warn("dry run found failures", "failed", summary.Failed)
+	}
+	return writeReport(config, summary, code)
+}
+
+// printPlan prints the actions of a dry run and its totals in config.Format.
+func printPlan(config *Config, actions []plannedAction, result *engine.Result) error {
+	if config.Format == "json" {
+		encoder := json.NewEncoder(os.Stdout)
+		encoder.SetIndent("", "  ")
+		return encoder.Encode(actions)
+	}
+
+	tw := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "ACTION\tPATH\tSIZE\tOUTPUT\tOUTPUT SIZE\tNOTES")
+	for _, action := range actions {
+		outputSize := ""
+		if action.Output != "" {
+			outputSize = "?"
+			if action.OutputSize > 0 || action.Action == engine.ModeDecompress {
+				outputSize = strconv.FormatInt(action.OutputSize, 10)
+			}
+		}
+
+		var notes []string
+		if action.Overwrite {
+			notes = append(notes, "overwrite")
+		}
+		if action.DeleteOriginal {
+			notes = append(notes, "delete-original")
+		}
+		if action.Error != "" {
+			notes = append(notes, action.Error)
+		}
+
+		fmt.Fprintf(tw, "%s\t%s\t%d\t%s\t%s\t%s\n", action.Action, action.Path, action.Size, action.Output, outputSize, strings.Join(notes, ", "))
+	}
+	tw.Flush()
+
+	fmt.Printf("\n%d files would be %sed, %d skipped, %d up to date, %d ignored, %d failed.\n", result.Planned, strings.TrimSuffix(config.Options.Mode, "e"), result.Skipped, result.UpToDate, result.Ignored, result.Failed)
+	return nil
+}
@@ -0,0 +1,107 @@
+package cli_logic
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/rifsxd/dvpl_go/engine"
+)
+
+// progressBarWidth is the number of cells in the progress bar.
+const progressBarWidth = 30
+
+// progressPrinter draws a progress bar on the last line of a terminal and
+// keeps it below the log lines written through it.
+type progressPrinter struct {
+	mu       sync.Mutex
+	w        io.Writer
+	enabled  bool
+	line     string
+	lastDraw time.Time
+}
+
+// newProgressPrinter returns a progressPrinter writing to stderr, which only
+// draws the bar if enabled is set.
+func newProgressPrinter(enabled bool) *progressPrinter {
+	return &progressPrinter{w: os.Stderr, enabled: enabled}
+}
+
+// Write writes log lines above the progress bar.
+func (p *progressPrinter) Write(b []byte) (int, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	p.clear()
+	n, err := p.w.Write(b)
+	p.draw()
+	return n, err
+}
+
+// update redraws the progress bar, at most ten times a second.
+func (p *progressPrinter) update(progress engine.Progress) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if !p.enabled {
+		return
+	}
+
+	total := progress.Discovered
+	filled := 0
+	if total > 0 {
+		filled = progressBarWidth * progress.Done / total
+	}
+	more := ""
+	if !progress.WalkDone {
+		more = "+"
+	}
+
+	p.line = fmt.Sprintf("[%s%s] %d/%d%s files, %s -> %s %s",
+		strings.Repeat("=", filled), strings.Repeat(" ", progressBarWidth-filled),
+		progress.Done, total, more, formatBytes(progress.BytesIn), formatBytes(progress.BytesOut), progress.Current)
+
+	if time.Since(p.lastDraw) >= 100*time.Millisecond {
+		p.clear()
+		p.draw()
+	}
+}
+
+// finish removes the progress bar.
+func (p *progressPrinter) finish() {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	p.clear()
+	p.line = ""
+}
+
+func (p *progressPrinter) clear() {
+	if p.enabled && p.line != "" {
+		fmt.Fprint(p.w, "\r\033[K")
+	}
+}
+
+func (p *progressPrinter) draw() {
+	if p.enabled && p.line != "" {
+		fmt.Fprint(p.w, p.line)
+		p.lastDraw = time.Now()
+	}
+}
+
+// formatBytes renders n with a binary unit suffix.
+func formatBytes(n int64) string {
+	const unit = 1024
+	if n < unit {
+		return fmt.Sprintf("%d B", n)
+	}
+	div, exp := int64(unit), 0
+	for m := n / unit; m >= unit; m /= unit {
+		div *= unit
+		exp++
+	}
+	return fmt.Sprintf("%.1f %ciB", float64(n)/float64(div), "KMGTPE"[exp])
+}
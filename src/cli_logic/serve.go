@@ -0,0 +1,185 @@
+package cli_logic
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"io/fs"
+	"log/slog"
+	"net/http"
+	"os"
+	"os/signal"
+	"path"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/rifsxd/dvpl_go/dvpl_logic"
+	"github.com/rifsxd/dvpl_go/engine"
+)
+
+// contentTypes completes the types known to the mime package for game data.
+var contentTypes = map[string]string{
+	".yaml": "application/yaml; charset=utf-8",
+	".yml":  "application/yaml; charset=utf-8",
+	".dds":  "image/vnd-ms.dds",
+	".tga":  "image/x-tga",
+}
+
+// runServe serves config.Paths[0] over HTTP until interrupted and returns the
+// exit code.
+func runServe(config *Config) int {
+	logger := config.logger
+	info, err := os.Stat(config.Paths[0])
+	if err != nil || !info.IsDir() {
+		if err == nil {
+			err = errors.New("not a directory")
+		}
+		logger.Error("serve failed", "path", config.Paths[0], "err", err)
+		return ExitFailure
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+
+	server := &http.Server{Addr: config.Addr, Handler: newServeHandler(config.Paths[0], logger)}
+	go func() {
+		<-ctx.Done()
+		shutdownCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		server.Shutdown(shutdownCtx)
+	}()
+
+	logger.Info("serving", "path", config.Paths[0], "addr", config.Addr)
+	if err := server.ListenAndServe(); !errors.Is(err, http.ErrServerClosed) {
+		logger.Error("serve failed", "err", err)
+		return ExitFailure
+	}
+	logger.Info("server stopped")
+	return ExitOK
+}
+
+// newServeHandler returns the handler serving the DVPL tree under root:
+//
+//	/path/name.xml         decompressed contents of name.xml.dvpl
+//	/path/name.xml?raw=1   the original name.xml.dvpl
+//	/path/                 directory listing, with DVPL files under their decompressed names
+//	/api/info?path=path    JSON footer details of the DVPL files under path
+func newServeHandler(root string, logger *slog.Logger) http.Handler {
+	raw := os.DirFS(root)
+	decoded := dvpl_logic.NewFS(raw)
+	listing := http.FileServer(http.FS(decoded))
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/api/info", func(w http.ResponseWriter, r *http.Request) {
+		serveInfo(w, r, root, raw, logger)
+	})
+	mux.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {
+		logger.Debug("request", "method", r.Method, "path", r.URL.Path, "query", r.URL.RawQuery)
+		name := strings.TrimPrefix(path.Clean(r.URL.Path), "/")
+		if name == "" {
+			name = "."
+		}
+
+		if r.URL.Query().Get("raw") == "1" {
+			serveRaw(w, r, raw, name)
+			return
+		}
+
+		file, err := decoded.Open(name)
+		if errors.Is(err, fs.ErrNotExist) || errors.Is(err, fs.ErrInvalid) {
+			listing.ServeHTTP(w, r)
+			return
+		}
+		if err != nil {
+			logger.Error("cannot decompress file", "path", name, "err", err)
+			http.Error(w, err.Error(), http.StatusUnprocessableEntity)
+			return
+		}
+		defer file.Close()
+		info, err := file.Stat()
+		if err != nil || info.IsDir() {
+			listing.ServeHTTP(w, r)
+			return
+		}
+
+		// Seeking decompresses the file, so damaged files fail before any
+		// header is written.
+		content, ok := file.(io.ReadSeeker)
+		if !ok {
+			listing.ServeHTTP(w, r)
+			return
+		}
+		if _, err := content.Seek(0, io.SeekStart); err != nil {
+			logger.Error("cannot decompress file", "path", name, "err", err)
+			http.Error(w, err.Error(), http.StatusUnprocessableEntity)
+			return
+		}
+		if contentType, ok := contentTypes[strings.ToLower(path.Ext(name))]; ok {
+			w.Header().Set("Content-Type", contentType)
+		}
+		http.ServeContent(w, r, info.Name(), info.ModTime(), content)
+	})
+	return mux
+}
+
+// serveRaw serves the file name as stored under root: the DVPL file it was
+// decompressed from, or the plain file itself.
+func serveRaw(w http.ResponseWriter, r *http.Request, raw fs.FS, name string) {
+	file, err := raw.Open(name + ".dvpl")
+	if err != nil {
+		file, err = raw.Open(name)
+	}
+	if err != nil {
+		http.NotFound(w, r)
+		return
+	}
+	defer file.Close()
+
+	info, err := file.Stat()
+	content, ok := file.(io.ReadSeeker)
+	if err != nil || info.IsDir() || !ok {
+		http.NotFound(w, r)
+		return
+	}
+	w.Header().Set("Content-Type", "application/octet-stream")
+	w.Header().Set("Content-Disposition", `attachment; filename="`+strings.ReplaceAll(info.Name(), `"`, "")+`"`)
+	http.ServeContent(w, r, info.Name(), info.ModTime(), content)
+}
+
+// serveInfo writes the footer details of the DVPL files under the path query
+// parameter, or of the whole tree, as a JSON list with paths relative to root.
+func serveInfo(w http.ResponseWriter, r *http.Request, root string, raw fs.FS, logger *slog.Logger) {
+	name := r.URL.Query().Get("path")
+	name = strings.Trim(path.Clean("/"+name), "/")
+	if name == "" {
+		name = "."
+	}
+	if _, err := fs.Stat(raw, name+".dvpl"); err == nil {
+		name += ".dvpl"
+	} else if _, err := fs.Stat(raw, name); err != nil {
+		http.Error(w, "not found", http.StatusNotFound)
+		return
+	}
+
+	infos, err := engine.Inspect(filepath.Join(root, filepath.FromSlash(name)))
+	if err != nil {
+		logger.Error("cannot inspect files", "path", name, "err", err)
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	if infos == nil {
+		infos = []engine.FileInfo{}
+	}
+	for i := range infos {
+		if rel, err := filepath.Rel(root, infos[i].Path); err == nil {
+			infos[i].Path = filepath.ToSlash(rel)
+		}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	encoder.Encode(infos)
+}
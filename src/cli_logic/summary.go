@@ -0,0 +1,120 @@
+package cli_logic
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"os"
+	"time"
+
+	"github.com/rifsxd/dvpl_go/engine"
+)
+
+// Exit codes of the command line converter.
+const (
+	ExitOK      = 0 // Every file was processed.
+	ExitFailure = 1 // Nothing was processed, or the run could not start.
+	ExitUsage   = 2 // The command line is invalid.
+	ExitPartial = 3 // Some files were processed and some failed.
+)
+
+// fileError names a file that could not be processed.
+type fileError struct {
+	Path  string `json:"path"`
+	Op    string `json:"op,omitempty"`
+	Error string `json:"error"`
+}
+
+// runSummary is the final summary of a run, printed at the end and written
+// as JSON by -report.
+type runSummary struct {
+	Mode       string      `json:"mode"`
+	Status     string      `json:"status"`           // "ok", "partial", "failed" or "cancelled".
+	DryRun     bool        `json:"dryRun,omitempty"` // Processed counts the planned conversions.
+	Processed  int         `json:"processed"`
+	Skipped    int         `json:"skipped"`
+	Salvaged   int         `json:"salvaged"`
+	Ignored    int         `json:"ignored"`
+	Failed     int         `json:"failed"`
+	NotRemoved int         `json:"notRemoved"` // Converted files whose original could not be removed.
+	BytesIn    int64       `json:"bytesIn"`
+	BytesOut   int64       `json:"bytesOut"`
+	Elapsed    float64     `json:"elapsedSeconds"`
+	Error      string      `json:"error,omitempty"` // Error that ended the run early.
+	Errors     []fileError `json:"errors"`
+}
+
+// newRunSummary starts timing a run in mode.
+func newRunSummary(mode string) (*runSummary, time.Time) {
+	return &runSummary{Mode: mode, Errors: []fileError{}}, time.Now()
+}
+
+// record notes the per-file errors of event.
+func (s *runSummary) record(event engine.Event) {
+	if event.Kind == engine.EventRemoveFailed {
+		s.NotRemoved++
+	}
+	if event.Kind == engine.EventFailed || event.Kind == engine.EventRemoveFailed || event.Kind == engine.EventSalvaged {
+		s.Errors = append(s.Errors, fileError{Path: event.Path, Op: event.Op, Error: event.Err.Error()})
+	}
+}
+
+// finish fills in the totals of result and the outcome of a run started at
+// start, and returns the matching exit code.
+func (s *runSummary) finish(result *engine.Result, err error, start time.Time) int {
+	s.Elapsed = time.Since(start).Seconds()
+	if result != nil {
+		s.Processed = result.Converted + result.Verified + result.Planned
+		s.Skipped = result.Skipped + result.UpToDate
+		s.Salvaged = result.Salvaged
+		s.Ignored = result.Ignored
+		s.Failed = result.Failed
+		s.BytesIn = result.BytesIn
+		s.BytesOut = result.BytesOut
+	}
+
+	switch {
+	case errors.Is(err, context.Canceled):
+		s.Status = "cancelled"
+	case s.Failed+s.Salvaged+s.NotRemoved > 0 && s.Processed > 0:
+		s.Status = "partial"
+	case s.Failed+s.Salvaged+s.NotRemoved > 0 || err != nil:
+		s.Status = "failed"
+	default:
+		s.Status = "ok"
+	}
+	if err != nil && s.Status != "partial" {
+		s.Error = err.Error()
+	}
+
+	switch s.Status {
+	case "ok":
+		return ExitOK
+	case "partial":
+		return ExitPartial
+	}
+	return ExitFailure
+}
+
+// print logs the summary.
+func (s *runSummary) print(logger *slog.Logger) {
+	attrs := []any{"processed", s.Processed, "skipped", s.Skipped, "failed", s.Failed}
+	if s.Salvaged > 0 {
+		attrs = append(attrs, "salvaged", s.Salvaged)
+	}
+	if s.NotRemoved > 0 {
+		attrs = append(attrs, "not_removed", s.NotRemoved)
+	}
+	logger.Info("summary", append(attrs,
+		"bytes_in", s.BytesIn, "bytes_out", s.BytesOut, "elapsed", time.Duration(s.Elapsed*float64(time.Second)))...)
+}
+
+// write saves the summary as JSON to name.
+func (s *runSummary) write(name string) error {
+	data, err := json.MarshalIndent(s, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(name, append(data, '\n'), 0644)
+}
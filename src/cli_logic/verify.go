@@ -0,0 +1,75 @@
+package cli_logic
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"os/signal"
+
+	"github.com/rifsxd/dvpl_go/engine"
+)
+
+// verifyFailure names a DVPL file that failed verification.
+type verifyFailure struct {
+	Path  string `json:"path"`
+	Error string `json:"error"`
+}
+
+// verifyReport is the JSON output of the verify mode.
+type verifyReport struct {
+	Checked int             `json:"checked"`
+	Failed  []verifyFailure `json:"failed"`
+}
+
+// runVerify checks every DVPL file under config.Paths without writing anything
+// and returns the exit code.
+func runVerify(config *Config) int {
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+
+	summary, start := newRunSummary(engine.ModeVerify)
+	report := verifyReport{Failed: []verifyFailure{}}
+	jsonOutput := config.Format == "json"
+
+	logger := config.logger
+	config.Options.Mode = engine.ModeVerify
+	config.Options.Logger = logger
+	config.Options.OnProgress = config.progress.update
+	config.Options.OnEvent = func(event engine.Event) {
+		summary.record(event)
+		if event.Kind == engine.EventFailed {
+			report.Failed = append(report.Failed, verifyFailure{Path: event.Path, Error: event.Err.Error()})
+		}
+	}
+
+	result, err := engine.New(config.Options).RunPaths(ctx, config.Paths...)
+	config.progress.finish()
+	code := summary.finish(result, err, start)
+	if result != nil {
+		report.Checked = result.Verified + result.Failed
+	}
+
+	if jsonOutput {
+		encoder := json.NewEncoder(os.Stdout)
+		encoder.SetIndent("", "  ")
+		encoder.Encode(report)
+	} else if len(report.Failed) > 0 {
+		color := useColor(os.Stdout)
+		fmt.Printf("\n%d of %d files failed verification:\n", len(report.Failed), report.Checked)
+		for _, failure := range report.Failed {
+			fmt.Printf("  %s: %s\n", paint(color, RedColor, failure.Path), failure.Error)
+		}
+	}
+
+	if err != nil {
+		logger.Error("verify failed", "err", err)
+	} else if len(report.Failed) > 0 {
+		logger.Error("verify failed", "failed", len(report.Failed), "checked", report.Checked)
+	} else {
+		logger.Info("verify finished", "valid", report.Checked)
+	}
+	summary.print(logger)
+
+	return writeReport(config, summary, code)
+}
@@ -1,26 +1,27 @@
 package dvpl_logic
 
 import (
-	"errors"
+	"fmt"
 	"hash/crc32"
+	"math"
+	"strings"
 
 	"github.com/pierrec/lz4/v4"
 )
 
 const (
-	RedColor    = "\033[31m"
-	GreenColor  = "\033[32m"
-	YellowColor = "\033[33m"
-	ResetColor  = "\033[0m"
+	dvplFooterSize = 20
+	dvplExtension  = ".dvpl"
 )
 
+// Compression types stored in the DVPL footer.
 const (
-	dvplFooterSize = 20
-	dvplTypeNone   = 0
-	dvplTypeLZ4    = 2
-	dvplExtension  = ".dvpl"
+	TypeNone  uint32 = 0
+	TypeLZ4   uint32 = 1
+	TypeLZ4HC uint32 = 2
 )
 
+// DVPLFooter represents the DVPL file footer data.
 type DVPLFooter struct {
 	OriginalSize   uint32
 	CompressedSize uint32
@@ -28,58 +29,274 @@ type DVPLFooter struct {
 	Type           uint32
 }
 
+// StorePolicy decides when data is stored uncompressed (footer type 0).
+type StorePolicy int
+
+const (
+	StoreAuto   StorePolicy = iota // Store when compression does not shrink the data.
+	StoreAlways                    // Always store, never compress.
+	StoreNever                     // Always compress, even if the output grows.
+)
+
+// CompressOptions selects how CompressDVPLWithOptions encodes its input.
+type CompressOptions struct {
+	Type  uint32      // Footer type, TypeLZ4 or TypeLZ4HC, 0 selects TypeLZ4HC.
+	Level int         // LZ4-HC compression level from 1 to 9, 0 selects DefaultLevel.
+	Store StorePolicy // When to fall back to an uncompressed payload.
+}
+
+// DefaultLevel is the LZ4-HC compression level used when none is given.
+const DefaultLevel = 9
+
+// DefaultCompressOptions matches the encoding of official game files.
+var DefaultCompressOptions = CompressOptions{Type: TypeLZ4HC, Level: DefaultLevel}
+
 // CompressDVPL compresses a buffer and returns the processed DVPL file buffer.
 func CompressDVPL(buffer []byte) ([]byte, error) {
+	return CompressDVPLWithOptions(buffer, DefaultCompressOptions)
+}
+
+// CompressDVPLWithOptions compresses a buffer as configured by options and
+// returns the processed DVPL file buffer.
+func CompressDVPLWithOptions(buffer []byte, options CompressOptions) ([]byte, error) {
+	if uint64(len(buffer)) > math.MaxUint32 {
+		return nil, &FormatError{Err: ErrTooLarge, ExpectedSize: math.MaxUint32, ActualSize: uint64(len(buffer))}
+	}
+
+	if options.Store == StoreAlways {
+		return storeDVPL(buffer), nil
+	}
+	if options.Type == TypeNone {
+		options.Type = DefaultCompressOptions.Type
+	}
+
 	compressedBlockSize := lz4.CompressBlockBound(len(buffer))
 	compressedBlock := make([]byte, compressedBlockSize)
 
-	n, err := lz4.CompressBlock(buffer, compressedBlock, nil)
+	var n int
+	var err error
+	switch options.Type {
+	case TypeLZ4:
+		var compressor lz4.Compressor
+		n, err = compressor.CompressBlock(buffer, compressedBlock)
+	case TypeLZ4HC:
+		level, levelErr := compressionLevel(options.Level)
+		if levelErr != nil {
+			return nil, levelErr
+		}
+		compressor := lz4.CompressorHC{Level: level}
+		n, err = compressor.CompressBlock(buffer, compressedBlock)
+	default:
+		return nil, fmt.Errorf("unsupported DVPL compression type %d", options.Type)
+	}
 	if err != nil {
 		return nil, err
 	}
 
+	if options.Store == StoreAuto && (n == 0 || n >= len(buffer)) {
+		return storeDVPL(buffer), nil
+	}
+
 	compressedBlock = compressedBlock[:n]
-	footerBuffer := createDVPLFooter(uint32(len(buffer)), uint32(n), crc32.ChecksumIEEE(compressedBlock), 2)
+	footerBuffer := createDVPLFooter(uint32(len(buffer)), uint32(n), crc32.ChecksumIEEE(compressedBlock), options.Type)
 	return append(compressedBlock, footerBuffer...), nil
 }
 
+// storeDVPL wraps a buffer into a DVPL file without compressing it.
+func storeDVPL(buffer []byte) []byte {
+	storedBlock := make([]byte, len(buffer), len(buffer)+dvplFooterSize)
+	copy(storedBlock, buffer)
+	footerBuffer := createDVPLFooter(uint32(len(buffer)), uint32(len(buffer)), crc32.ChecksumIEEE(buffer), TypeNone)
+	return append(storedBlock, footerBuffer...)
+}
+
+// compressionLevel maps a level from 1 to 9 onto the LZ4-HC search depth.
+func compressionLevel(level int) (lz4.CompressionLevel, error) {
+	if level == 0 {
+		level = DefaultLevel
+	}
+	if level < 1 || level > 9 {
+		return 0, fmt.Errorf("invalid LZ4-HC compression level %d, must be between 1 and 9", level)
+	}
+	return lz4.Level1 << (level - 1), nil
+}
+
+// ParseStorePolicy parses a store policy given as "auto", "always" or "never".
+func ParseStorePolicy(s string) (StorePolicy, error) {
+	switch strings.ToLower(s) {
+	case "auto":
+		return StoreAuto, nil
+	case "always":
+		return StoreAlways, nil
+	case "never":
+		return StoreNever, nil
+	}
+	return 0, fmt.Errorf("unknown store policy %q", s)
+}
+
+// ParseType parses a compression type given by name ("LZ4", "LZ4HC") or by
+// its footer number.
+func ParseType(s string) (uint32, error) {
+	switch strings.ToLower(s) {
+	case "1", "lz4":
+		return TypeLZ4, nil
+	case "2", "lz4hc", "lz4_hc":
+		return TypeLZ4HC, nil
+	}
+	return 0, fmt.Errorf("unknown DVPL compression type %q", s)
+}
+
+// DecompressOptions limits what DecompressDVPLWithOptions accepts.
+type DecompressOptions struct {
+	MaxOutputSize int64 // Largest decompressed size accepted, 0 selects DefaultMaxOutputSize.
+
+	// Salvage makes DecompressDVPLWithOptions recover what it can from a
+	// damaged file instead of failing: the data is returned together with
+	// a *SalvageError describing what was wrong. Readers ignore it.
+	Salvage bool
+}
+
+// DefaultMaxOutputSize is the largest decompressed size accepted by default.
+// Game files are far smaller, a larger OriginalSize means a damaged footer.
+const DefaultMaxOutputSize = 1 << 30
+
+// DefaultDecompressOptions are used by DecompressDVPL and NewReader.
+var DefaultDecompressOptions = DecompressOptions{MaxOutputSize: DefaultMaxOutputSize}
+
+// maxLZ4Ratio bounds how much an LZ4 block can expand: every input byte
+// yields at most 255 output bytes.
+const maxLZ4Ratio = 255
+
 // DecompressDVPL decompresses a DVPL buffer and returns the uncompressed file buffer.
 func DecompressDVPL(buffer []byte) ([]byte, error) {
+	return DecompressDVPLWithOptions(buffer, DefaultDecompressOptions)
+}
+
+// DecompressDVPLWithOptions decompresses a DVPL buffer within the limits of
+// options and returns the uncompressed file buffer.
+func DecompressDVPLWithOptions(buffer []byte, options DecompressOptions) ([]byte, error) {
+	data, err := decompressDVPL(buffer, options)
+	if err != nil && options.Salvage {
+		return salvageDVPL(buffer, err, options)
+	}
+	return data, err
+}
+
+func decompressDVPL(buffer []byte, options DecompressOptions) ([]byte, error) {
 	footerData, err := readDVPLFooter(buffer)
 	if err != nil {
 		return nil, err
 	}
 
-	targetBlock := buffer[:len(buffer)-20]
+	targetBlock := buffer[:len(buffer)-dvplFooterSize]
+	if err := checkFooter(footerData, int64(len(targetBlock)), options); err != nil {
+		return nil, err
+	}
+
+	return decodeDVPLBlock(targetBlock, footerData)
+}
+
+// checkFooter rejects footers that do not match a payload of payloadSize
+// bytes or that describe data no valid DVPL file can hold, before anything
+// is allocated for the decoded data.
+func checkFooter(footerData *DVPLFooter, payloadSize int64, options DecompressOptions) error {
+	if uint64(payloadSize) != uint64(footerData.CompressedSize) {
+		return &FormatError{
+			Err:          ErrSizeMismatch,
+			Footer:       footerData,
+			Offset:       payloadSize,
+			ExpectedSize: uint64(footerData.CompressedSize),
+			ActualSize:   uint64(payloadSize),
+		}
+	}
 
-	if uint32(len(targetBlock)) != footerData.CompressedSize {
-		return nil, errors.New("DVPLSizeMismatch")
+	offset := payloadSize
+	switch footerData.Type {
+	case TypeNone:
+		if footerData.OriginalSize != footerData.CompressedSize {
+			return &FormatError{
+				Err:          ErrTypeSizeMismatch,
+				Footer:       footerData,
+				Offset:       offset,
+				ExpectedSize: uint64(footerData.OriginalSize),
+				ActualSize:   uint64(footerData.CompressedSize),
+			}
+		}
+	case TypeLZ4, TypeLZ4HC:
+		if limit := uint64(footerData.CompressedSize) * maxLZ4Ratio; uint64(footerData.OriginalSize) > limit {
+			return &FormatError{
+				Err:          ErrInvalidSize,
+				Footer:       footerData,
+				Offset:       offset,
+				ExpectedSize: limit,
+				ActualSize:   uint64(footerData.OriginalSize),
+			}
+		}
+	default:
+		return &FormatError{
+			Err:    ErrUnknownFormat,
+			Footer: footerData,
+			Offset: offset + 12,
+		}
 	}
 
-	if crc32.ChecksumIEEE(targetBlock) != footerData.CRC32 {
-		return nil, errors.New("DVPLCRC32Mismatch")
+	maxOutputSize := options.MaxOutputSize
+	if maxOutputSize <= 0 {
+		maxOutputSize = DefaultMaxOutputSize
+	}
+	if int64(footerData.OriginalSize) > maxOutputSize {
+		return &FormatError{
+			Err:          ErrTooLarge,
+			Footer:       footerData,
+			Offset:       offset,
+			ExpectedSize: uint64(maxOutputSize),
+			ActualSize:   uint64(footerData.OriginalSize),
+		}
 	}
+	return nil
+}
 
-	if footerData.Type == 0 {
-		if !(footerData.OriginalSize == footerData.CompressedSize && footerData.Type == 0) {
-			return nil, errors.New("DVPLTypeSizeMismatch")
+// decodeDVPLBlock checks a DVPL payload against its footer, which must have
+// passed checkFooter, and returns the decoded data.
+func decodeDVPLBlock(targetBlock []byte, footerData *DVPLFooter) ([]byte, error) {
+	if sum := crc32.ChecksumIEEE(targetBlock); sum != footerData.CRC32 {
+		return nil, &FormatError{
+			Err:           ErrCRC32Mismatch,
+			Footer:        footerData,
+			ExpectedCRC32: footerData.CRC32,
+			ActualCRC32:   sum,
 		}
+	}
+
+	if footerData.Type == TypeNone {
 		return targetBlock, nil
-	} else if footerData.Type == 1 || footerData.Type == 2 {
+	} else if footerData.Type == TypeLZ4 || footerData.Type == TypeLZ4HC {
 		deDVPLBlock := make([]byte, footerData.OriginalSize)
 		n, err := lz4.UncompressBlock(targetBlock, deDVPLBlock)
 		if err != nil {
-			return nil, err
+			return nil, &FormatError{
+				Err:    fmt.Errorf("%w: %w", ErrDecodeFailed, err),
+				Footer: footerData,
+			}
 		}
 
 		if uint32(n) != footerData.OriginalSize {
-			return nil, errors.New("DVPLDecodeSizeMismatch")
+			return nil, &FormatError{
+				Err:          ErrDecodeSizeMismatch,
+				Footer:       footerData,
+				ExpectedSize: uint64(footerData.OriginalSize),
+				ActualSize:   uint64(n),
+			}
 		}
 
 		return deDVPLBlock, nil
 	}
 
-	return nil, errors.New("UNKNOWN DVPL FORMAT")
+	return nil, &FormatError{
+		Err:    ErrUnknownFormat,
+		Footer: footerData,
+		Offset: int64(footerData.CompressedSize) + 12,
+	}
 }
 
 // createDVPLFooter creates a DVPL footer from the provided data.
@@ -99,9 +316,16 @@ func readLittleEndianUint32(b []byte, offset int) uint32 {
 
 // readDVPLFooter reads the DVPL footer data from a DVPL buffer.
 func readDVPLFooter(buffer []byte) (*DVPLFooter, error) {
-	footerBuffer := buffer[len(buffer)-20:]
-	if string(footerBuffer[16:]) != "DVPL" || len(footerBuffer) != 20 {
-		return nil, errors.New(RedColor + "InvalidDVPLFooter" + ResetColor)
+	if len(buffer) < dvplFooterSize {
+		return nil, &FormatError{Err: ErrInvalidFooter, ExpectedSize: dvplFooterSize, ActualSize: uint64(len(buffer))}
+	}
+	return parseDVPLFooter(buffer[len(buffer)-dvplFooterSize:], int64(len(buffer)-dvplFooterSize))
+}
+
+// parseDVPLFooter decodes the 20 footer bytes of a DVPL file found at offset.
+func parseDVPLFooter(footerBuffer []byte, offset int64) (*DVPLFooter, error) {
+	if len(footerBuffer) != 20 || string(footerBuffer[16:]) != "DVPL" {
+		return nil, &FormatError{Err: ErrInvalidFooter, Offset: offset}
 	}
 
 	footerData := &DVPLFooter{}
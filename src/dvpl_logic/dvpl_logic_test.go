@@ -0,0 +1,27 @@
+package dvpl_logic
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestCompressZeroOptions(t *testing.T) {
+	data := bytes.Repeat([]byte("<a>1</a>"), 64)
+
+	encoded, err := CompressDVPLWithOptions(data, CompressOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	footer, err := readDVPLFooter(encoded)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if footer.Type != DefaultCompressOptions.Type {
+		t.Errorf("zero options wrote type %d, want %d", footer.Type, DefaultCompressOptions.Type)
+	}
+
+	decoded, err := DecompressDVPL(encoded)
+	if err != nil || !bytes.Equal(decoded, data) {
+		t.Fatalf("round trip failed: %v", err)
+	}
+}
@@ -0,0 +1,49 @@
+package dvpl_logic
+
+import (
+	"errors"
+	"fmt"
+)
+
+// Sentinel errors reported by the DVPL decoder and writer. Decoding failures
+// are wrapped in a *FormatError, so test for them with errors.Is.
+var (
+	ErrInvalidFooter      = errors.New("InvalidDVPLFooter")
+	ErrSizeMismatch       = errors.New("DVPLSizeMismatch")
+	ErrCRC32Mismatch      = errors.New("DVPLCRC32Mismatch")
+	ErrTypeSizeMismatch   = errors.New("DVPLTypeSizeMismatch")
+	ErrDecodeSizeMismatch = errors.New("DVPLDecodeSizeMismatch")
+	ErrDecodeFailed       = errors.New("DVPLDecodeFailed")
+	ErrInvalidSize        = errors.New("DVPLInvalidSize")
+	ErrTooLarge           = errors.New("DVPLOutputTooLarge")
+	ErrUnknownFormat      = errors.New("UNKNOWN DVPL FORMAT")
+	ErrWriterClosed       = errors.New("DVPLWriterClosed")
+)
+
+// FormatError describes why a DVPL file could not be decoded.
+type FormatError struct {
+	Err    error       // One of the sentinel errors above, possibly wrapping a cause.
+	Footer *DVPLFooter // Parsed footer, nil if the footer itself is invalid.
+	Offset int64       // Offset in the file at which the problem was detected.
+
+	ExpectedSize uint64 // Size required by the footer, if relevant.
+	ActualSize   uint64 // Size actually found, if relevant.
+
+	ExpectedCRC32 uint32 // Checksum stored in the footer, if relevant.
+	ActualCRC32   uint32 // Checksum of the payload, if relevant.
+}
+
+func (e *FormatError) Error() string {
+	switch {
+	case errors.Is(e.Err, ErrCRC32Mismatch):
+		return fmt.Sprintf("%v at offset %d: footer has 0x%08x, payload has 0x%08x", e.Err, e.Offset, e.ExpectedCRC32, e.ActualCRC32)
+	case e.ExpectedSize != e.ActualSize:
+		return fmt.Sprintf("%v at offset %d: expected %d bytes, got %d", e.Err, e.Offset, e.ExpectedSize, e.ActualSize)
+	default:
+		return fmt.Sprintf("%v at offset %d", e.Err, e.Offset)
+	}
+}
+
+func (e *FormatError) Unwrap() error {
+	return e.Err
+}
@@ -0,0 +1,82 @@
+package dvpl_logic
+
+import (
+	"bytes"
+	"hash/crc32"
+	"io"
+)
+
+// ReadFooter reads and parses the footer of the DVPL file of the given size
+// read from r without touching its payload.
+func ReadFooter(r io.ReaderAt, size int64) (*DVPLFooter, error) {
+	if size < dvplFooterSize {
+		return nil, &FormatError{Err: ErrInvalidFooter, ExpectedSize: dvplFooterSize, ActualSize: uint64(size)}
+	}
+
+	footerBuffer := make([]byte, dvplFooterSize)
+	if _, err := r.ReadAt(footerBuffer, size-dvplFooterSize); err != nil {
+		return nil, err
+	}
+
+	return parseDVPLFooter(footerBuffer, size-dvplFooterSize)
+}
+
+// VerifyCRC32 checks that the payload of the DVPL file of the given size read
+// from r has the length and checksum recorded in footerData. The payload is
+// streamed, not decompressed.
+func VerifyCRC32(r io.ReaderAt, size int64, footerData *DVPLFooter) error {
+	if size < dvplFooterSize {
+		return &FormatError{Err: ErrInvalidFooter, Footer: footerData, ExpectedSize: dvplFooterSize, ActualSize: uint64(size)}
+	}
+	if uint64(size-dvplFooterSize) != uint64(footerData.CompressedSize) {
+		return &FormatError{
+			Err:          ErrSizeMismatch,
+			Footer:       footerData,
+			Offset:       size - dvplFooterSize,
+			ExpectedSize: uint64(footerData.CompressedSize),
+			ActualSize:   uint64(size - dvplFooterSize),
+		}
+	}
+
+	hash := crc32.NewIEEE()
+	if _, err := io.Copy(hash, io.NewSectionReader(r, 0, size-dvplFooterSize)); err != nil {
+		return err
+	}
+
+	if sum := hash.Sum32(); sum != footerData.CRC32 {
+		return &FormatError{
+			Err:           ErrCRC32Mismatch,
+			Footer:        footerData,
+			ExpectedCRC32: footerData.CRC32,
+			ActualCRC32:   sum,
+		}
+	}
+	return nil
+}
+
+// TypeName returns a readable name for the compression type of the footer.
+func (f *DVPLFooter) TypeName() string {
+	switch f.Type {
+	case TypeNone:
+		return "none"
+	case TypeLZ4:
+		return "LZ4"
+	case TypeLZ4HC:
+		return "LZ4HC"
+	}
+	return "unknown"
+}
+
+// Ratio returns the compressed size as a fraction of the original size.
+func (f *DVPLFooter) Ratio() float64 {
+	if f.OriginalSize == 0 {
+		return 0
+	}
+	return float64(f.CompressedSize) / float64(f.OriginalSize)
+}
+
+// IsStored reports whether a DVPL buffer holds its payload uncompressed.
+func IsStored(buffer []byte) bool {
+	footerData, err := ReadFooter(bytes.NewReader(buffer), int64(len(buffer)))
+	return err == nil && footerData.Type == TypeNone
+}
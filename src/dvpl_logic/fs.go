@@ -0,0 +1,279 @@
+package dvpl_logic
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"io/fs"
+	"path"
+	"sort"
+	"strings"
+)
+
+// dvplFS is the file system returned by NewFS.
+type dvplFS struct {
+	fsys fs.FS
+}
+
+// NewFS returns a read-only view of fsys in which every "*.dvpl" file appears
+// under its name without the extension, with its decompressed contents and
+// its OriginalSize as size. Other files are passed through unchanged, and
+// win over a DVPL file that decompresses to the same name. The returned
+// file system implements fs.ReadDirFS and fs.StatFS, and its files implement
+// io.Seeker and io.ReaderAt, so it can be served with http.FS.
+func NewFS(fsys fs.FS) fs.FS {
+	return &dvplFS{fsys: fsys}
+}
+
+// Open opens the named file, decompressing it if it is stored as DVPL.
+func (f *dvplFS) Open(name string) (fs.File, error) {
+	if !fs.ValidPath(name) {
+		return nil, &fs.PathError{Op: "open", Path: name, Err: fs.ErrInvalid}
+	}
+
+	// Names ending in the extension can only be directories, which are
+	// listed unchanged, or DVPL files named after stripping it once.
+	isDVPLName := strings.HasSuffix(name, dvplExtension)
+	if !isDVPLName {
+		file, err := f.openRaw(name, false)
+		if !errors.Is(err, fs.ErrNotExist) {
+			return file, err
+		}
+	}
+
+	file, err := f.fsys.Open(name + dvplExtension)
+	if err == nil {
+		info, buffer, err := statDVPL(file)
+		if err == nil && !info.IsDir() {
+			return &dvplFile{file: file, info: info, name: name, buffer: buffer}, nil
+		}
+		file.Close()
+		if err != nil {
+			return nil, &fs.PathError{Op: "open", Path: name, Err: err}
+		}
+	}
+
+	if isDVPLName {
+		return f.openRaw(name, true)
+	}
+	return nil, &fs.PathError{Op: "open", Path: name, Err: fs.ErrNotExist}
+}
+
+// openRaw opens the named file or directory of the underlying file system,
+// wrapping directories so they list DVPL files under their decompressed
+// names. With dirOnly, regular files are reported as not existing.
+func (f *dvplFS) openRaw(name string, dirOnly bool) (fs.File, error) {
+	file, err := f.fsys.Open(name)
+	if err != nil {
+		return nil, err
+	}
+	info, err := file.Stat()
+	if err != nil {
+		file.Close()
+		return nil, err
+	}
+	if info.IsDir() {
+		return &dvplDir{File: file, fsys: f, name: name}, nil
+	}
+	if dirOnly {
+		file.Close()
+		return nil, &fs.PathError{Op: "open", Path: name, Err: fs.ErrNotExist}
+	}
+	return file, nil
+}
+
+// Stat returns the file info of the named file. The size of a DVPL file is
+// read from its footer without decompressing it.
+func (f *dvplFS) Stat(name string) (fs.FileInfo, error) {
+	file, err := f.Open(name)
+	if err != nil {
+		return nil, &fs.PathError{Op: "stat", Path: name, Err: unwrapPathError(err)}
+	}
+	defer file.Close()
+	return file.Stat()
+}
+
+// ReadDir lists the named directory sorted by name, with DVPL files under
+// their decompressed names.
+func (f *dvplFS) ReadDir(name string) ([]fs.DirEntry, error) {
+	entries, err := fs.ReadDir(f.fsys, name)
+	if err != nil {
+		return nil, err
+	}
+
+	names := make(map[string]bool, len(entries))
+	for _, entry := range entries {
+		names[entry.Name()] = true
+	}
+
+	list := make([]fs.DirEntry, 0, len(entries))
+	for _, entry := range entries {
+		stripped := strings.TrimSuffix(entry.Name(), dvplExtension)
+		switch {
+		case stripped == entry.Name() || entry.IsDir():
+			list = append(list, entry)
+		case stripped == "" || names[stripped]:
+			// Hidden: it cannot be opened under its stripped name.
+		default:
+			list = append(list, &dvplDirEntry{DirEntry: entry, fsys: f, path: path.Join(name, stripped)})
+		}
+	}
+	sort.Slice(list, func(i, j int) bool { return list[i].Name() < list[j].Name() })
+	return list, nil
+}
+
+// statDVPL returns the info of an open DVPL file under its decompressed name
+// and size. Files that do not implement io.ReaderAt are read completely to
+// find the footer, and their contents are returned too.
+func statDVPL(file fs.File) (fs.FileInfo, []byte, error) {
+	info, err := file.Stat()
+	if err != nil || info.IsDir() {
+		return info, nil, err
+	}
+
+	var footer *DVPLFooter
+	var buffer []byte
+	if r, ok := file.(io.ReaderAt); ok {
+		footer, err = ReadFooter(r, info.Size())
+	} else if buffer, err = io.ReadAll(file); err == nil {
+		footer, err = readDVPLFooter(buffer)
+	}
+	if err != nil {
+		return nil, nil, err
+	}
+
+	name := strings.TrimSuffix(info.Name(), dvplExtension)
+	return &dvplFileInfo{FileInfo: info, name: name, size: int64(footer.OriginalSize)}, buffer, nil
+}
+
+func unwrapPathError(err error) error {
+	var pathErr *fs.PathError
+	if errors.As(err, &pathErr) {
+		return pathErr.Err
+	}
+	return err
+}
+
+// dvplFileInfo is the info of a DVPL file under its decompressed name and size.
+type dvplFileInfo struct {
+	fs.FileInfo
+	name string
+	size int64
+}
+
+func (i *dvplFileInfo) Name() string { return i.name }
+func (i *dvplFileInfo) Size() int64  { return i.size }
+
+// dvplDirEntry is a directory entry of a DVPL file under its decompressed name.
+type dvplDirEntry struct {
+	fs.DirEntry
+	fsys *dvplFS
+	path string
+}
+
+func (e *dvplDirEntry) Name() string { return path.Base(e.path) }
+
+func (e *dvplDirEntry) Info() (fs.FileInfo, error) {
+	return e.fsys.Stat(e.path)
+}
+
+// dvplFile is an open DVPL file, decompressed on the first read.
+type dvplFile struct {
+	file   fs.File
+	info   fs.FileInfo
+	name   string
+	buffer []byte // Contents of the DVPL file, if already read.
+	data   *bytes.Reader
+	err    error
+}
+
+func (f *dvplFile) Stat() (fs.FileInfo, error) {
+	return f.info, nil
+}
+
+// load decompresses the file once.
+func (f *dvplFile) load() error {
+	if f.data != nil || f.err != nil {
+		return f.err
+	}
+
+	buffer := f.buffer
+	if buffer == nil {
+		if r, ok := f.file.(io.ReaderAt); ok {
+			var info fs.FileInfo
+			if info, f.err = f.file.Stat(); f.err == nil {
+				buffer, f.err = io.ReadAll(io.NewSectionReader(r, 0, info.Size()))
+			}
+		} else {
+			buffer, f.err = io.ReadAll(f.file)
+		}
+	}
+	if f.err == nil {
+		var data []byte
+		data, f.err = DecompressDVPL(buffer)
+		f.data = bytes.NewReader(data)
+		f.buffer = nil
+	}
+	if f.err != nil {
+		f.err = &fs.PathError{Op: "read", Path: f.name, Err: f.err}
+	}
+	return f.err
+}
+
+func (f *dvplFile) Read(p []byte) (int, error) {
+	if err := f.load(); err != nil {
+		return 0, err
+	}
+	return f.data.Read(p)
+}
+
+func (f *dvplFile) ReadAt(p []byte, off int64) (int, error) {
+	if err := f.load(); err != nil {
+		return 0, err
+	}
+	return f.data.ReadAt(p, off)
+}
+
+func (f *dvplFile) Seek(offset int64, whence int) (int64, error) {
+	if err := f.load(); err != nil {
+		return 0, err
+	}
+	return f.data.Seek(offset, whence)
+}
+
+func (f *dvplFile) Close() error {
+	return f.file.Close()
+}
+
+// dvplDir is an open directory listing DVPL files under their decompressed
+// names.
+type dvplDir struct {
+	fs.File
+	fsys    *dvplFS
+	name    string
+	entries []fs.DirEntry
+	read    bool
+}
+
+func (d *dvplDir) ReadDir(n int) ([]fs.DirEntry, error) {
+	if !d.read {
+		entries, err := d.fsys.ReadDir(d.name)
+		if err != nil {
+			return nil, err
+		}
+		d.entries, d.read = entries, true
+	}
+
+	if n <= 0 {
+		entries := d.entries
+		d.entries = nil
+		return entries, nil
+	}
+	if len(d.entries) == 0 {
+		return nil, io.EOF
+	}
+	n = min(n, len(d.entries))
+	entries := d.entries[:n]
+	d.entries = d.entries[n:]
+	return entries, nil
+}
@@ -0,0 +1,111 @@
+package dvpl_logic
+
+import (
+	"bytes"
+	"io/fs"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path"
+	"testing"
+	"testing/fstest"
+	"text/template"
+)
+
+func TestFS(t *testing.T) {
+	root := os.DirFS(path.Join(testFiles, "XML"))
+	fsys := NewFS(root)
+
+	if err := fstest.TestFS(fsys, "item_defs/ribbons.xml", "destructibles.xml", "chassis_effects.yaml"); err != nil {
+		t.Fatal(err)
+	}
+
+	err := fs.WalkDir(fsys, ".", func(name string, d fs.DirEntry, err error) error {
+		if err != nil || d.IsDir() {
+			return err
+		}
+		raw, err := fs.ReadFile(root, name+".dvpl")
+		if err != nil {
+			return err
+		}
+		want, err := DecompressDVPL(raw)
+		if err != nil {
+			return err
+		}
+		got, err := fs.ReadFile(fsys, name)
+		if err != nil {
+			return err
+		}
+		if !bytes.Equal(got, want) {
+			t.Errorf("%s: contents differ from DecompressDVPL", name)
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := template.ParseFS(fsys, "item_defs/*.xml"); err != nil {
+		t.Fatal(err)
+	}
+
+	recorder := httptest.NewRecorder()
+	http.FileServer(http.FS(fsys)).ServeHTTP(recorder, httptest.NewRequest("GET", "/destructibles.xml", nil))
+	want, _ := fs.ReadFile(fsys, "destructibles.xml")
+	if recorder.Code != http.StatusOK || !bytes.Equal(recorder.Body.Bytes(), want) {
+		t.Fatalf("http.FS served status %d with %d bytes, want %d bytes", recorder.Code, recorder.Body.Len(), len(want))
+	}
+}
+
+func TestFSShadowing(t *testing.T) {
+	packed, err := CompressDVPL([]byte("packed"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	fsys := NewFS(fstest.MapFS{
+		"a.txt":             {Data: []byte("plain")},
+		"a.txt.dvpl":        {Data: packed},
+		"b.txt.dvpl":        {Data: packed},
+		"c.txt.dvpl":        {Data: []byte("damaged")},
+		"d.dvpl/e.txt.dvpl": {Data: packed},
+	})
+
+	clean := NewFS(fstest.MapFS{
+		"b.txt.dvpl":        {Data: packed},
+		"d.dvpl/e.txt.dvpl": {Data: packed},
+	})
+	if err := fstest.TestFS(clean, "b.txt", "d.dvpl/e.txt"); err != nil {
+		t.Error(err)
+	}
+	if info, err := fs.Stat(fsys, "d.dvpl"); err != nil || !info.IsDir() {
+		t.Errorf("Stat(d.dvpl) = %v, %v, want the directory", info, err)
+	}
+	if data, err := fs.ReadFile(fsys, "d.dvpl/e.txt"); err != nil || string(data) != "packed" {
+		t.Errorf("d.dvpl/e.txt = %q, %v, want the decompressed file", data, err)
+	}
+
+	if data, err := fs.ReadFile(fsys, "a.txt"); err != nil || string(data) != "plain" {
+		t.Errorf("a.txt = %q, %v, want the plain file", data, err)
+	}
+	if data, err := fs.ReadFile(fsys, "b.txt"); err != nil || string(data) != "packed" {
+		t.Errorf("b.txt = %q, %v, want the decompressed file", data, err)
+	}
+	if info, err := fs.Stat(fsys, "b.txt"); err != nil || info.Size() != int64(len("packed")) || info.Name() != "b.txt" {
+		t.Errorf("Stat(b.txt) = %v, %v", info, err)
+	}
+	if _, err := fs.ReadFile(fsys, "c.txt"); err == nil {
+		t.Errorf("reading a damaged file succeeded")
+	}
+
+	entries, err := fs.ReadDir(fsys, ".")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var names []string
+	for _, entry := range entries {
+		names = append(names, entry.Name())
+	}
+	if len(names) != 4 || names[0] != "a.txt" || names[1] != "b.txt" || names[2] != "c.txt" || names[3] != "d.dvpl" {
+		t.Errorf("ReadDir = %v, want [a.txt b.txt c.txt d.dvpl]", names)
+	}
+}
@@ -0,0 +1,173 @@
+package dvpl_logic
+
+import (
+	"bytes"
+	"errors"
+	"hash/crc32"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// testFiles is the directory of sample DVPL files used as the seed corpus.
+const testFiles = "../test_files"
+
+// fuzzMaxOutputSize keeps allocations small while fuzzing.
+const fuzzMaxOutputSize = 1 << 24
+
+// addSeeds adds every file under testFiles to the corpus of f, together with
+// truncated and damaged variants of the first one.
+func addSeeds(f *testing.F) {
+	f.Helper()
+
+	var first []byte
+	err := filepath.WalkDir(testFiles, func(path string, d fs.DirEntry, err error) error {
+		if err != nil || d.IsDir() {
+			return err
+		}
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return err
+		}
+		if first == nil {
+			first = data
+		}
+		f.Add(data)
+		return nil
+	})
+	if err != nil {
+		f.Fatal(err)
+	}
+
+	f.Add([]byte{})
+	f.Add([]byte("DVPL"))
+	if len(first) > dvplFooterSize {
+		f.Add(first[:len(first)-1])
+		f.Add(first[len(first)-dvplFooterSize:])
+		damaged := bytes.Clone(first)
+		damaged[len(damaged)-dvplFooterSize+12] = 0xff // Type
+		f.Add(damaged)
+		damaged = bytes.Clone(first)
+		damaged[len(damaged)-dvplFooterSize+3] = 0xff // OriginalSize
+		f.Add(damaged)
+		damaged = bytes.Clone(first)
+		damaged[len(damaged)/2] ^= 0xff // Payload
+		f.Add(append(damaged, "trailing junk"...))
+	}
+
+	// A stored file whose footer is consistent except for OriginalSize,
+	// which only the strict decode rejects.
+	payload := []byte("0123456789abcdef")
+	f.Add(append(bytes.Clone(payload), createDVPLFooter(4, uint32(len(payload)), crc32.ChecksumIEEE(payload), TypeNone)...))
+}
+
+// checkDecoded fails unless a successful decode is consistent with its footer.
+func checkDecoded(t *testing.T, data, decoded []byte) {
+	t.Helper()
+
+	footer, err := readDVPLFooter(data)
+	if err != nil {
+		t.Fatalf("decoded a file with an unreadable footer: %v", err)
+	}
+	if uint64(len(decoded)) != uint64(footer.OriginalSize) {
+		t.Fatalf("decoded %d bytes, footer says %d", len(decoded), footer.OriginalSize)
+	}
+	if len(decoded) > fuzzMaxOutputSize {
+		t.Fatalf("decoded %d bytes, more than the limit of %d", len(decoded), fuzzMaxOutputSize)
+	}
+}
+
+// checkError fails unless err is nil or a *FormatError.
+func checkError(t *testing.T, err error) {
+	t.Helper()
+
+	var formatErr *FormatError
+	if err != nil && !errors.As(err, &formatErr) {
+		t.Fatalf("error is not a *FormatError: %v", err)
+	}
+}
+
+func FuzzDecompressDVPL(f *testing.F) {
+	addSeeds(f)
+	options := DecompressOptions{MaxOutputSize: fuzzMaxOutputSize}
+
+	f.Fuzz(func(t *testing.T, data []byte) {
+		decoded, err := DecompressDVPLWithOptions(data, options)
+		checkError(t, err)
+		if err == nil {
+			checkDecoded(t, data, decoded)
+		}
+	})
+}
+
+func FuzzReader(f *testing.F) {
+	addSeeds(f)
+	options := DecompressOptions{MaxOutputSize: fuzzMaxOutputSize}
+
+	f.Fuzz(func(t *testing.T, data []byte) {
+		want, wantErr := DecompressDVPLWithOptions(data, options)
+
+		var got []byte
+		reader, err := NewReaderWithOptions(bytes.NewReader(data), int64(len(data)), options)
+		if err == nil {
+			got, err = io.ReadAll(reader)
+		}
+		checkError(t, err)
+
+		if (err == nil) != (wantErr == nil) {
+			t.Fatalf("Reader returned %v, DecompressDVPL returned %v", err, wantErr)
+		}
+		if err == nil && !bytes.Equal(got, want) {
+			t.Fatalf("Reader and DecompressDVPL disagree")
+		}
+	})
+}
+
+func FuzzRoundTrip(f *testing.F) {
+	f.Add([]byte{}, uint8(TypeLZ4HC))
+	f.Add([]byte("<root><a>1</a><a>1</a><a>1</a></root>"), uint8(TypeLZ4))
+
+	f.Fuzz(func(t *testing.T, data []byte, typeVal uint8) {
+		options := CompressOptions{Type: TypeLZ4 + uint32(typeVal%2)}
+		encoded, err := CompressDVPLWithOptions(data, options)
+		if err != nil {
+			t.Fatal(err)
+		}
+		decoded, err := DecompressDVPL(encoded)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !bytes.Equal(decoded, data) {
+			t.Fatalf("round trip changed the data")
+		}
+	})
+}
+
+func FuzzSalvage(f *testing.F) {
+	addSeeds(f)
+	options := DecompressOptions{MaxOutputSize: fuzzMaxOutputSize}
+
+	f.Fuzz(func(t *testing.T, data []byte) {
+		want, wantErr := DecompressDVPLWithOptions(data, options)
+		got, err := salvageDVPL(data, wantErr, options)
+		if len(got) > fuzzMaxOutputSize {
+			t.Fatalf("salvaged %d bytes, more than the limit of %d", len(got), fuzzMaxOutputSize)
+		}
+
+		var salvageErr *SalvageError
+		if err != nil && !errors.As(err, &salvageErr) {
+			checkError(t, err)
+		}
+		if wantErr == nil && (err != nil || !bytes.Equal(got, want)) {
+			t.Fatalf("salvaging an intact file changed it: %v", err)
+		}
+		if wantErr != nil && (err == nil || got != nil && salvageErr == nil) {
+			t.Fatalf("salvaging a damaged file returned %d bytes without a *SalvageError: %v", len(got), err)
+		}
+		if salvageErr != nil && (len(salvageErr.Problems) == 0 || !errors.Is(salvageErr.Problems[0], wantErr)) {
+			t.Fatalf("salvage problems %v do not start with the decode error %v", salvageErr.Problems, wantErr)
+		}
+	})
+}
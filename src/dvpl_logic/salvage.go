@@ -0,0 +1,219 @@
+package dvpl_logic
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"strings"
+)
+
+// SalvageError is returned together with the recovered data by
+// DecompressDVPLWithOptions when Salvage is set and the file is damaged. It
+// wraps every problem that was ignored, so errors.Is still finds them.
+type SalvageError struct {
+	Footer        *DVPLFooter // Footer used to decode the payload.
+	FooterOffset  int64       // Offset of that footer in the file.
+	TrailingBytes int64       // Bytes found after the footer.
+	Recovered     uint64      // Bytes of data recovered.
+	Expected      uint64      // Bytes of data the footer promises.
+	Problems      []error     // What was wrong, as *FormatError values.
+}
+
+func (e *SalvageError) Error() string {
+	problems := make([]string, len(e.Problems))
+	for i, problem := range e.Problems {
+		problems[i] = problem.Error()
+	}
+	return fmt.Sprintf("salvaged %d of %d bytes: %s", e.Recovered, e.Expected, strings.Join(problems, "; "))
+}
+
+func (e *SalvageError) Unwrap() []error {
+	return e.Problems
+}
+
+// salvageDVPL decodes as much of a damaged DVPL buffer as it can. It ignores
+// the checksum, looks for the footer before any trailing junk and keeps the
+// data decoded up to the first error in the LZ4 block. cause is the error of
+// the strict decode; when it is set, any data comes with a *SalvageError
+// listing it first, even if nothing else is found wrong.
+func salvageDVPL(buffer []byte, cause error, options DecompressOptions) ([]byte, error) {
+	footerData, footerOffset := locateFooter(buffer)
+	if footerData == nil {
+		if cause != nil {
+			return nil, cause
+		}
+		return nil, &FormatError{Err: ErrInvalidFooter, ExpectedSize: dvplFooterSize, ActualSize: uint64(len(buffer))}
+	}
+
+	report := &SalvageError{
+		Footer:        footerData,
+		FooterOffset:  footerOffset,
+		TrailingBytes: int64(len(buffer)) - footerOffset - dvplFooterSize,
+		Expected:      uint64(footerData.OriginalSize),
+	}
+	if cause != nil {
+		report.Problems = append(report.Problems, cause)
+	}
+	if report.TrailingBytes > 0 {
+		report.add(&FormatError{Err: ErrInvalidFooter, Footer: footerData, Offset: int64(len(buffer)) - dvplFooterSize})
+	}
+
+	targetBlock := buffer[:footerOffset]
+	if uint64(len(targetBlock)) != uint64(footerData.CompressedSize) {
+		report.add(&FormatError{
+			Err:          ErrSizeMismatch,
+			Footer:       footerData,
+			Offset:       footerOffset,
+			ExpectedSize: uint64(footerData.CompressedSize),
+			ActualSize:   uint64(len(targetBlock)),
+		})
+		if uint64(len(targetBlock)) > uint64(footerData.CompressedSize) {
+			targetBlock = targetBlock[:footerData.CompressedSize]
+		}
+	}
+
+	if sum := crc32.ChecksumIEEE(targetBlock); sum != footerData.CRC32 {
+		report.add(&FormatError{
+			Err:           ErrCRC32Mismatch,
+			Footer:        footerData,
+			ExpectedCRC32: footerData.CRC32,
+			ActualCRC32:   sum,
+		})
+	}
+
+	outputSize := uint64(footerData.OriginalSize)
+	maxOutputSize := options.MaxOutputSize
+	if maxOutputSize <= 0 {
+		maxOutputSize = DefaultMaxOutputSize
+	}
+	if outputSize > uint64(maxOutputSize) {
+		report.add(&FormatError{
+			Err:          ErrTooLarge,
+			Footer:       footerData,
+			Offset:       footerOffset,
+			ExpectedSize: uint64(maxOutputSize),
+			ActualSize:   outputSize,
+		})
+		outputSize = uint64(maxOutputSize)
+	}
+
+	var data []byte
+	if footerData.Type == TypeNone {
+		data = targetBlock[:min(uint64(len(targetBlock)), outputSize)]
+	} else {
+		outputSize = min(outputSize, uint64(len(targetBlock))*maxLZ4Ratio)
+		data = make([]byte, outputSize)
+		data = data[:decodeLZ4Partial(targetBlock, data)]
+	}
+
+	report.Recovered = uint64(len(data))
+	if report.Recovered != report.Expected {
+		report.add(&FormatError{
+			Err:          ErrDecodeSizeMismatch,
+			Footer:       footerData,
+			ExpectedSize: report.Expected,
+			ActualSize:   report.Recovered,
+		})
+	}
+
+	if len(report.Problems) == 0 {
+		return data, nil
+	}
+	return data, report
+}
+
+// add records problem unless an earlier one, such as the error of the strict
+// decode, already reports the same kind of damage.
+func (e *SalvageError) add(problem *FormatError) {
+	for _, earlier := range e.Problems {
+		if errors.Is(earlier, problem.Err) {
+			return
+		}
+	}
+	e.Problems = append(e.Problems, problem)
+}
+
+// locateFooter finds the footer of a DVPL buffer that may have junk appended.
+// A footer whose CompressedSize matches its offset is preferred; otherwise
+// the last footer with a known type is used. It returns nil if there is none.
+func locateFooter(buffer []byte) (*DVPLFooter, int64) {
+	var fallback *DVPLFooter
+	fallbackOffset := int64(-1)
+
+	end := len(buffer)
+	for {
+		i := bytes.LastIndex(buffer[:end], []byte("DVPL"))
+		offset := i - (dvplFooterSize - 4)
+		if i < 0 || offset < 0 {
+			break
+		}
+		end = i
+
+		footerData, err := parseDVPLFooter(buffer[offset:i+4], int64(offset))
+		if err != nil || footerData.Type > TypeLZ4HC {
+			continue
+		}
+		if uint64(footerData.CompressedSize) == uint64(offset) {
+			return footerData, int64(offset)
+		}
+		if fallback == nil {
+			fallback, fallbackOffset = footerData, int64(offset)
+		}
+	}
+	return fallback, fallbackOffset
+}
+
+// decodeLZ4Partial decodes the LZ4 block src into dst up to the first error
+// and returns the number of bytes written.
+func decodeLZ4Partial(src, dst []byte) int {
+	si, di := 0, 0
+	for si < len(src) && di < len(dst) {
+		token := src[si]
+		si++
+
+		literals, ok := readLZ4Length(src, &si, int(token>>4))
+		if !ok {
+			return di
+		}
+		n := min(literals, len(src)-si, len(dst)-di)
+		copy(dst[di:], src[si:si+n])
+		si += n
+		di += n
+		if n < literals || si+2 > len(src) {
+			return di
+		}
+
+		offset := int(src[si]) | int(src[si+1])<<8
+		si += 2
+		if offset == 0 || offset > di {
+			return di
+		}
+
+		match, ok := readLZ4Length(src, &si, int(token&15))
+		if !ok {
+			return di
+		}
+		for match += 4; match > 0 && di < len(dst); match-- {
+			dst[di] = dst[di-offset]
+			di++
+		}
+	}
+	return di
+}
+
+// readLZ4Length reads the extension bytes of a length starting at n.
+func readLZ4Length(src []byte, si *int, n int) (int, bool) {
+	if n != 15 {
+		return n, true
+	}
+	for *si < len(src) {
+		b := src[*si]
+		*si++
+		n += int(b)
+		if b != 255 {
+			return n, true
+		}
+	}
+	return n, false
+}
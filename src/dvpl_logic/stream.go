@@ -0,0 +1,110 @@
+package dvpl_logic
+
+import (
+	"bytes"
+	"io"
+)
+
+// Reader yields the decompressed contents of a DVPL file.
+type Reader struct {
+	src    *io.SectionReader
+	footer DVPLFooter
+	data   io.Reader
+	err    error // First error reading or decoding the payload, returned by every later Read.
+}
+
+// NewReader validates the footer of the DVPL file of the given size read from r
+// and returns a Reader over its decompressed contents. The payload is read and
+// checked on the first call to Read.
+func NewReader(r io.ReaderAt, size int64) (*Reader, error) {
+	return NewReaderWithOptions(r, size, DefaultDecompressOptions)
+}
+
+// NewReaderWithOptions is like NewReader with the limits of options.
+func NewReaderWithOptions(r io.ReaderAt, size int64, options DecompressOptions) (*Reader, error) {
+	footerData, err := ReadFooter(r, size)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := checkFooter(footerData, size-dvplFooterSize, options); err != nil {
+		return nil, err
+	}
+
+	return &Reader{
+		src:    io.NewSectionReader(r, 0, size-dvplFooterSize),
+		footer: *footerData,
+	}, nil
+}
+
+// Footer returns the footer of the underlying DVPL file.
+func (r *Reader) Footer() DVPLFooter {
+	return r.footer
+}
+
+// Read reads decompressed data into p. Once the payload fails to read or
+// decode, every call returns that error.
+func (r *Reader) Read(p []byte) (int, error) {
+	if r.err != nil {
+		return 0, r.err
+	}
+	if r.data == nil {
+		targetBlock := make([]byte, r.footer.CompressedSize)
+		if _, r.err = io.ReadFull(r.src, targetBlock); r.err != nil {
+			return 0, r.err
+		}
+
+		var deDVPLBlock []byte
+		if deDVPLBlock, r.err = decodeDVPLBlock(targetBlock, &r.footer); r.err != nil {
+			return 0, r.err
+		}
+		r.data = bytes.NewReader(deDVPLBlock)
+	}
+	return r.data.Read(p)
+}
+
+// Writer buffers everything written to it and emits a single DVPL file,
+// payload followed by footer, to the underlying writer on Close.
+type Writer struct {
+	w       io.Writer
+	options CompressOptions
+	buf     bytes.Buffer
+	closed  bool
+}
+
+// NewWriter returns a Writer that writes a DVPL file to w.
+func NewWriter(w io.Writer) *Writer {
+	return NewWriterWithOptions(w, DefaultCompressOptions)
+}
+
+// NewWriterWithOptions returns a Writer that writes a DVPL file to w encoded
+// as configured by options.
+func NewWriterWithOptions(w io.Writer, options CompressOptions) *Writer {
+	return &Writer{w: w, options: options}
+}
+
+// Write buffers p for compression.
+func (w *Writer) Write(p []byte) (int, error) {
+	if w.closed {
+		return 0, ErrWriterClosed
+	}
+	return w.buf.Write(p)
+}
+
+// Close compresses the buffered data and writes it together with the DVPL
+// footer. It does not close the underlying writer.
+func (w *Writer) Close() error {
+	if w.closed {
+		return nil
+	}
+	w.closed = true
+
+	processedBlock, err := CompressDVPLWithOptions(w.buf.Bytes(), w.options)
+	if err != nil {
+		return err
+	}
+	w.buf.Reset()
+
+	_, err = w.w.Write(processedBlock)
+	return err
+}
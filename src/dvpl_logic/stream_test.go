@@ -0,0 +1,27 @@
+package dvpl_logic
+
+import (
+	"bytes"
+	"errors"
+	"testing"
+)
+
+func TestReaderKeepsError(t *testing.T) {
+	encoded, err := CompressDVPL(bytes.Repeat([]byte("<a>1</a>"), 64))
+	if err != nil {
+		t.Fatal(err)
+	}
+	encoded[0] ^= 0xff // Payload, the footer CRC32 no longer matches.
+
+	reader, err := NewReader(bytes.NewReader(encoded), int64(len(encoded)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	buf := make([]byte, 16)
+	for i := 0; i < 2; i++ {
+		n, err := reader.Read(buf)
+		if n != 0 || !errors.Is(err, ErrCRC32Mismatch) {
+			t.Fatalf("Read %d returned %d, %v, want ErrCRC32Mismatch", i+1, n, err)
+		}
+	}
+}
@@ -0,0 +1,14 @@
+//go:build !linux && !darwin && !windows
+
+package engine
+
+import (
+	"io/fs"
+	"time"
+)
+
+// accessTime falls back to the modification time where the access time is
+// not available.
+func accessTime(info fs.FileInfo) time.Time {
+	return info.ModTime()
+}
@@ -0,0 +1,43 @@
+package engine
+
+import "sync"
+
+// memoryBudget is a counting semaphore over bytes. A single request larger than
+// the whole budget waits until it has the budget to itself.
+type memoryBudget struct {
+	mu        sync.Mutex
+	cond      *sync.Cond
+	total     int64
+	available int64
+}
+
+func newMemoryBudget(total int64) *memoryBudget {
+	budget := &memoryBudget{total: total, available: total}
+	budget.cond = sync.NewCond(&budget.mu)
+	return budget
+}
+
+func (b *memoryBudget) acquire(n int64) {
+	n = b.clamp(n)
+	b.mu.Lock()
+	for b.available < n {
+		b.cond.Wait()
+	}
+	b.available -= n
+	b.mu.Unlock()
+}
+
+func (b *memoryBudget) release(n int64) {
+	n = b.clamp(n)
+	b.mu.Lock()
+	b.available += n
+	b.mu.Unlock()
+	b.cond.Broadcast()
+}
+
+func (b *memoryBudget) clamp(n int64) int64 {
+	if n > b.total {
+		return b.total
+	}
+	return n
+}
@@ -0,0 +1,162 @@
+package engine
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// ConflictPolicy decides what happens when the output of a conversion already
+// exists.
+type ConflictPolicy string
+
+const (
+	ConflictOverwrite ConflictPolicy = "overwrite" // Replace the existing file. The default.
+	ConflictSkip      ConflictPolicy = "skip"      // Leave the existing file and the source alone.
+	ConflictRename    ConflictPolicy = "rename"    // Write to a free name such as "foo (1).xml".
+	ConflictNewer     ConflictPolicy = "newer"     // Replace the existing file only if the source is newer.
+	ConflictFail      ConflictPolicy = "fail"      // Abort the run before writing anything.
+)
+
+// ErrConflict is returned by Run under ConflictFail when outputs already exist.
+var ErrConflict = errors.New("output file already exists")
+
+// ParseConflictPolicy parses a policy name as accepted on the command line.
+func ParseConflictPolicy(s string) (ConflictPolicy, error) {
+	switch policy := ConflictPolicy(strings.ToLower(s)); policy {
+	case ConflictOverwrite, ConflictSkip, ConflictRename, ConflictNewer, ConflictFail:
+		return policy, nil
+	}
+	return "", fmt.Errorf("unknown conflict policy %q", s)
+}
+
+// resolveConflict applies the conflict policy to a planned output. It returns
+// the name to write to, or skip set when the file must be left alone. An
+// output written earlier in the run from another source, as when several
+// paths are mirrored into OutputDir, is never replaced: it is skipped or
+// renamed as the policy says, and fails the file otherwise.
+func (p *Processor) resolveConflict(source, target string) (name string, skip bool, err error) {
+	p.outputsMu.Lock()
+	defer p.outputsMu.Unlock()
+
+	if other, ok := p.outputs[outputKey(target)]; ok && other != source {
+		switch p.options.OnConflict {
+		case ConflictSkip:
+			return target, true, nil
+		case ConflictRename:
+			name = freeName(target)
+			claimOutput(p.outputs, name, source)
+			return name, false, nil
+		}
+		return "", false, fmt.Errorf("%w: %s (also the output of %s)", ErrConflict, target, other)
+	}
+
+	name, skip, err = p.resolveExisting(source, target)
+	if err == nil && !skip {
+		claimOutput(p.outputs, name, source)
+	}
+	return name, skip, err
+}
+
+// resolveExisting applies the conflict policy to an output that may exist.
+func (p *Processor) resolveExisting(source, target string) (name string, skip bool, err error) {
+	targetInfo, err := os.Stat(target)
+	if errors.Is(err, fs.ErrNotExist) {
+		return target, false, nil
+	}
+	if err != nil {
+		return "", false, err
+	}
+
+	switch p.options.OnConflict {
+	case ConflictSkip:
+		return target, true, nil
+	case ConflictRename:
+		return freeName(target), false, nil
+	case ConflictNewer:
+		sourceInfo, err := os.Stat(source)
+		if err != nil {
+			return "", false, err
+		}
+		return target, !sourceInfo.ModTime().After(targetInfo.ModTime()), nil
+	case ConflictFail:
+		return "", false, fmt.Errorf("%w: %s", ErrConflict, target)
+	}
+	return target, false, nil
+}
+
+// claimOutput records in outputs that source is converted into target and
+// returns the other source already converted into it, if any.
+func claimOutput(outputs map[string]string, target, source string) string {
+	key := outputKey(target)
+	if other, ok := outputs[key]; ok && other != source {
+		return other
+	}
+	outputs[key] = source
+	return ""
+}
+
+// outputKey identifies target however the path leading to it was spelled.
+func outputKey(target string) string {
+	if abs, err := filepath.Abs(target); err == nil {
+		return abs
+	}
+	return target
+}
+
+// freeName returns the first of "name (1).ext", "name (2).ext", ... that does
+// not exist. For a DVPL target the counter goes before the extension of the
+// file it decompresses to, as in "name (1).xml.dvpl".
+func freeName(target string) string {
+	base := target
+	ext := ""
+	if strings.HasSuffix(base, dvplExtension) {
+		base = strings.TrimSuffix(base, dvplExtension)
+		ext = dvplExtension
+	}
+	ext = filepath.Ext(base) + ext
+	base = strings.TrimSuffix(target, ext)
+	for i := 1; ; i++ {
+		name := fmt.Sprintf("%s (%d)%s", base, i, ext)
+		if _, err := os.Lstat(name); errors.Is(err, fs.ErrNotExist) {
+			return name
+		}
+	}
+}
+
+// checkConflicts walks the run ahead of time and fails if any output exists
+// that would be written, or would be written twice. targets holds the outputs
+// of the paths already checked, as filled in by claimOutput.
+func (p *Processor) checkConflicts(ctx context.Context, directoryOrFile string, info fs.FileInfo, budget *memoryBudget, targets map[string]string) error {
+	var conflicts []string
+	err := p.walkFiles(ctx, directoryOrFile, info, func(task fileTask) {
+		isCompression, selected := p.selects(task.path)
+		if !selected {
+			return
+		}
+		target := p.outputName(task, isCompression)
+		if isCompression && p.options.Incremental && upToDate(task.path, target, budget) {
+			return
+		}
+		if _, err := os.Lstat(target); err == nil {
+			conflicts = append(conflicts, target)
+		} else if other := claimOutput(targets, target, task.path); other != "" {
+			conflicts = append(conflicts, target+" (also the output of "+other+")")
+		}
+	}, func(string, error) {})
+	if err != nil {
+		return err
+	}
+
+	switch len(conflicts) {
+	case 0:
+		return nil
+	case 1:
+		return fmt.Errorf("%w: %s", ErrConflict, conflicts[0])
+	}
+	return fmt.Errorf("%w: %s and %d more", ErrConflict, conflicts[0], len(conflicts)-1)
+}
@@ -0,0 +1,90 @@
+package engine
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/rifsxd/dvpl_go/dvpl_logic"
+)
+
+func TestFreeName(t *testing.T) {
+	tests := []struct {
+		target string
+		exists []string
+		want   string
+	}{
+		{"foo.xml.dvpl", nil, "foo (1).xml.dvpl"},
+		{"foo.xml.dvpl", []string{"foo (1).xml.dvpl"}, "foo (2).xml.dvpl"},
+		{"foo.dvpl", nil, "foo (1).dvpl"},
+		{"foo.xml", nil, "foo (1).xml"},
+		{"foo.xml", []string{"foo (1).xml"}, "foo (2).xml"},
+		{"foo", nil, "foo (1)"},
+	}
+	for _, test := range tests {
+		dir := t.TempDir()
+		for _, name := range test.exists {
+			if err := os.WriteFile(filepath.Join(dir, name), nil, 0644); err != nil {
+				t.Fatal(err)
+			}
+		}
+		got := freeName(filepath.Join(dir, test.target))
+		if want := filepath.Join(dir, test.want); got != want {
+			t.Errorf("freeName(%q) with %v = %q, want %q", test.target, test.exists, filepath.Base(got), test.want)
+		}
+	}
+}
+
+func TestOutputDirCollision(t *testing.T) {
+	tests := []struct {
+		policy  ConflictPolicy
+		want    []string
+		failed  int
+		skipped int
+		runErr  bool
+	}{
+		{policy: ConflictOverwrite, want: []string{"x.xml"}, failed: 1},
+		{policy: ConflictNewer, want: []string{"x.xml"}, failed: 1},
+		{policy: ConflictSkip, want: []string{"x.xml"}, skipped: 1},
+		{policy: ConflictRename, want: []string{"x (1).xml", "x.xml"}},
+		{policy: ConflictFail, want: nil, runErr: true},
+	}
+
+	for _, test := range tests {
+		t.Run(string(test.policy), func(t *testing.T) {
+			dir := t.TempDir()
+			for _, root := range []string{"a", "b"} {
+				data, err := dvpl_logic.CompressDVPL([]byte(root))
+				if err != nil {
+					t.Fatal(err)
+				}
+				writeTestFile(t, filepath.Join(dir, root, "x.xml.dvpl"), string(data))
+			}
+
+			out := filepath.Join(dir, "out")
+			options := Options{Mode: ModeDecompress, OutputDir: out, OnConflict: test.policy}
+			result, err := New(options).RunPaths(context.Background(), filepath.Join(dir, "a"), filepath.Join(dir, "b"))
+			if (err != nil) != test.runErr || test.runErr && !errors.Is(err, ErrConflict) {
+				t.Fatalf("RunPaths = %v, want error %t", err, test.runErr)
+			}
+			if !test.runErr && (result.Failed != test.failed || result.Skipped != test.skipped) {
+				t.Errorf("result = %+v, want %d failed and %d skipped", result, test.failed, test.skipped)
+			}
+
+			var names []string
+			entries, _ := os.ReadDir(out)
+			for _, entry := range entries {
+				names = append(names, entry.Name())
+			}
+			if !reflect.DeepEqual(names, test.want) {
+				t.Errorf("outputs = %v, want %v", names, test.want)
+			}
+			if data, err := os.ReadFile(filepath.Join(out, "x.xml")); err == nil && string(data) != "a" {
+				t.Errorf("x.xml = %q, want the output of the first path", data)
+			}
+		})
+	}
+}
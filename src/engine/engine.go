@@ -0,0 +1,618 @@
+// Package engine converts trees of files to and from DVPL. It is shared by the
+// CLI and GUI front-ends and can be embedded by other tools.
+package engine
+
+import (
+	"context"
+	"errors"
+	"io"
+	"io/fs"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"sync"
+
+	"github.com/rifsxd/dvpl_go/dvpl_logic"
+)
+
+// Conversion modes.
+const (
+	ModeCompress   = "compress"
+	ModeDecompress = "decompress"
+	ModeVerify     = "verify" // Fully decode DVPL files without writing anything.
+)
+
+const dvplExtension = ".dvpl"
+
+// DefaultMemoryBudget bounds the file data held in memory when none is configured.
+const DefaultMemoryBudget = 512 << 20
+
+// Options configures a Processor.
+type Options struct {
+	Mode          string // ModeCompress, ModeDecompress or ModeVerify.
+	KeepOriginals bool   // Keep source files after conversion, otherwise they are removed once the output is verified.
+
+	// OutputDir, if set, receives the outputs in a tree mirroring the input
+	// tree. The input tree is never modified, whatever KeepOriginals says.
+	OutputDir string
+
+	// Include, if not empty, limits a directory walk to files matching one of
+	// these globs; Exclude skips files and directories matching any of them.
+	// Globs are relative to the walked directory and support "**". Ignore
+	// files (IgnoreFileName) are honoured in every directory, and hidden and
+	// VCS directories are skipped unless IncludeHidden is set.
+	Include       []string
+	Exclude       []string
+	IncludeHidden bool
+
+	// OnConflict decides what happens when an output already exists. The
+	// zero value overwrites it.
+	OnConflict ConflictPolicy
+
+	// NoPreserve writes outputs with mode 0644 and the current time instead
+	// of copying the permission bits, access and modification times of the
+	// source.
+	NoPreserve bool
+
+	// Incremental makes compression leave a file alone when its output is
+	// not older than it and decompresses to the same contents.
+	Incremental bool
+
+	// DryRun reports an EventPlanned for every file that would be converted
+	// instead of converting it. Only DVPL footers, and the files compared for
+	// Incremental, are read.
+	DryRun bool
+
+	Compress     dvpl_logic.CompressOptions
+	Decompress   dvpl_logic.DecompressOptions // With Salvage set, damaged files are recovered into PartialSuffix outputs.
+	Jobs         int                          // Number of files converted concurrently, 0 for one per CPU.
+	MemoryBudget int64                        // Bytes of file data held in memory at once, 0 for DefaultMemoryBudget.
+
+	// Logger, if set, receives a record for every event: failures at error
+	// level, conversions at info level, ignored files at debug level.
+	Logger *slog.Logger
+
+	// OnEvent, if set, receives an event for every file. It is called from a
+	// single goroutine, in walk order.
+	OnEvent func(Event)
+
+	// OnProgress, if set, receives the running totals whenever a file is
+	// discovered, started or finished. Calls are serialized.
+	OnProgress func(Progress)
+}
+
+// Progress is a snapshot of a running conversion.
+type Progress struct {
+	Discovered int    // Files found by the walker so far.
+	Done       int    // Files finished, whatever their outcome.
+	WalkDone   bool   // Discovered is final.
+	BytesIn    int64  // Bytes read by finished conversions.
+	BytesOut   int64  // Bytes written by finished conversions.
+	Current    string // File most recently started.
+}
+
+// EventKind tells what happened to a file.
+type EventKind int
+
+const (
+	EventConverted    EventKind = iota // The file was converted.
+	EventIgnored                       // The file does not match the mode.
+	EventFailed                        // The file could not be converted, see Op and Err.
+	EventRemoveFailed                  // The file was converted but the original could not be removed.
+	EventVerified                      // The file passed verification.
+	EventPlanned                       // The file would be converted, see Overwrite and RemoveOriginal.
+	EventSkipped                       // The file was left alone because its output exists, see OnConflict.
+	EventUpToDate                      // The file was left alone because its output is up to date, see Incremental.
+	EventSalvaged                      // The file is damaged, what could be recovered was written to Output, see Err.
+)
+
+// Event reports the outcome of a single file.
+type Event struct {
+	Kind      EventKind
+	Path      string // Source file.
+	Output    string // Written file, if any.
+	Op        string // Failed operation: "walk", "read", "conflict", "convert", "verify", "write", "check" or "remove".
+	Err       error
+	StoredRaw bool  // Output is an uncompressed (type 0) DVPL file.
+	BytesIn   int64 // Size of the source file.
+	BytesOut  int64 // Size of the written file, or of the decompressed data for a planned decompression.
+
+	Overwrite      bool // A planned conversion replaces an existing file.
+	RemoveOriginal bool // A planned conversion removes the source file.
+}
+
+// Result summarises a run.
+type Result struct {
+	Converted int
+	Verified  int
+	Planned   int
+	Skipped   int
+	UpToDate  int
+	Salvaged  int
+	Ignored   int
+	Failed    int
+	StoredRaw int // Compressed files stored uncompressed (type 0).
+	BytesIn   int64
+	BytesOut  int64
+}
+
+// add adds the counts of other to r.
+func (r *Result) add(other *Result) {
+	r.Converted += other.Converted
+	r.Verified += other.Verified
+	r.Planned += other.Planned
+	r.Skipped += other.Skipped
+	r.UpToDate += other.UpToDate
+	r.Salvaged += other.Salvaged
+	r.Ignored += other.Ignored
+	r.Failed += other.Failed
+	r.StoredRaw += other.StoredRaw
+	r.BytesIn += other.BytesIn
+	r.BytesOut += other.BytesOut
+}
+
+// Processor converts files as configured by its Options. It runs one
+// conversion at a time.
+type Processor struct {
+	options Options
+
+	progressMu sync.Mutex
+	progress   Progress
+
+	outputsMu sync.Mutex
+	outputs   map[string]string // Source of every output of the run, see claimOutput.
+}
+
+// New returns a Processor for options.
+func New(options Options) *Processor {
+	if options.Jobs <= 0 {
+		options.Jobs = runtime.NumCPU()
+	}
+	if options.MemoryBudget <= 0 {
+		options.MemoryBudget = DefaultMemoryBudget
+	}
+	if options.Logger == nil {
+		options.Logger = slog.New(discardHandler{})
+	}
+	return &Processor{options: options}
+}
+
+// fileTask is a single file found by the walker.
+type fileTask struct {
+	index int
+	path  string
+	rel   string // Path relative to the walk root, the base name for a single file.
+}
+
+// fileResult holds the events produced for a single file.
+type fileResult struct {
+	index  int
+	events []Event
+}
+
+// Run converts every matching file under directoryOrFile. Files are converted
+// by a pool of workers; events are delivered in walk order regardless of the
+// order in which workers finish. A single file reports its own failure as the
+// returned error, a directory only reports failures through events.
+//
+// Cancelling ctx stops the walk and skips files not yet started; files being
+// converted are finished. Run then returns the partial result and ctx.Err().
+func (p *Processor) Run(ctx context.Context, directoryOrFile string) (*Result, error) {
+	return p.RunPaths(ctx, directoryOrFile)
+}
+
+// RunPaths is like Run for several directories or files, processed one after
+// the other into a single Result. Every path is checked, and under
+// ConflictFail every output too, before anything is written. Files of
+// different paths converted into the same output, as with OutputDir, are
+// treated as conflicts, see resolveConflict.
+func (p *Processor) RunPaths(ctx context.Context, paths ...string) (*Result, error) {
+	paths = append([]string(nil), paths...)
+	infos := make([]fs.FileInfo, len(paths))
+	for i, path := range paths {
+		paths[i] = filepath.Clean(path)
+		info, err := os.Stat(paths[i])
+		if err != nil {
+			return nil, err
+		}
+		infos[i] = info
+	}
+
+	p.updateProgress(func(progress *Progress) { *progress = Progress{} })
+	p.options.Logger.Debug("run started", "mode", p.options.Mode, "paths", paths, "jobs", p.options.Jobs, "dry_run", p.options.DryRun)
+
+	budget := newMemoryBudget(p.options.MemoryBudget)
+	if p.options.OnConflict == ConflictFail && p.options.Mode != ModeVerify && !p.options.DryRun {
+		targets := make(map[string]string)
+		for i, path := range paths {
+			if err := p.checkConflicts(ctx, path, infos[i], budget, targets); err != nil {
+				return &Result{}, err
+			}
+		}
+	}
+	p.outputs = make(map[string]string)
+
+	total := &Result{}
+	var lastErr error
+	for i, path := range paths {
+		result, err := p.run(ctx, path, infos[i], budget)
+		total.add(result)
+		if ctx.Err() != nil {
+			return total, ctx.Err()
+		}
+		if err != nil {
+			lastErr = err
+		}
+	}
+	return total, lastErr
+}
+
+// run converts the files under a single directory or file.
+func (p *Processor) run(ctx context.Context, directoryOrFile string, info fs.FileInfo, budget *memoryBudget) (*Result, error) {
+	tasks := make(chan fileTask)
+	results := make(chan fileResult)
+	walkErr := make(chan error, 1)
+
+	go func() {
+		defer close(tasks)
+		walkErr <- p.walkFiles(ctx, directoryOrFile, info, func(task fileTask) {
+			p.updateProgress(func(progress *Progress) { progress.Discovered++ })
+			tasks <- task
+		}, func(path string, err error) {
+			results <- fileResult{index: -1, events: []Event{{Kind: EventFailed, Path: path, Op: "walk", Err: err}}}
+		})
+		p.updateProgress(func(progress *Progress) { progress.WalkDone = true })
+	}()
+
+	var workers sync.WaitGroup
+	for i := 0; i < p.options.Jobs; i++ {
+		workers.Add(1)
+		go func() {
+			defer workers.Done()
+			for task := range tasks {
+				// Skipped files still send a result to keep the ordering intact.
+				if ctx.Err() != nil {
+					results <- fileResult{index: task.index}
+					continue
+				}
+
+				p.updateProgress(func(progress *Progress) { progress.Current = task.path })
+				events := p.processFile(task, budget)
+				p.updateProgress(func(progress *Progress) {
+					progress.Done++
+					for _, event := range events {
+						progress.BytesIn += event.BytesIn
+						progress.BytesOut += event.BytesOut
+					}
+				})
+				results <- fileResult{index: task.index, events: events}
+			}
+		}()
+	}
+
+	go func() {
+		workers.Wait()
+		close(results)
+	}()
+
+	// Results are delivered in walk order, holding back any that finish early.
+	result := &Result{}
+	pending := make(map[int]fileResult)
+	next := 0
+	var lastErr error
+	for fileRes := range results {
+		if fileRes.index < 0 {
+			lastErr = p.deliver(result, fileRes.events)
+			continue
+		}
+		pending[fileRes.index] = fileRes
+		for {
+			ready, ok := pending[next]
+			if !ok {
+				break
+			}
+			delete(pending, next)
+			next++
+			lastErr = p.deliver(result, ready.events)
+		}
+	}
+
+	if err := <-walkErr; err != nil && !errors.Is(err, ctx.Err()) {
+		return result, err
+	}
+	if ctx.Err() != nil {
+		return result, ctx.Err()
+	}
+
+	if !info.IsDir() {
+		return result, lastErr
+	}
+	return result, nil
+}
+
+// updateProgress applies update to the running totals and reports them.
+func (p *Processor) updateProgress(update func(*Progress)) {
+	p.progressMu.Lock()
+	defer p.progressMu.Unlock()
+
+	update(&p.progress)
+	if p.options.OnProgress != nil {
+		p.options.OnProgress(p.progress)
+	}
+}
+
+// deliver counts events into result, passes them to OnEvent and returns the
+// error of a failed conversion, if any.
+func (p *Processor) deliver(result *Result, events []Event) error {
+	var err error
+	for _, event := range events {
+		switch event.Kind {
+		case EventConverted:
+			result.Converted++
+			result.BytesIn += event.BytesIn
+			result.BytesOut += event.BytesOut
+			if event.StoredRaw {
+				result.StoredRaw++
+			}
+		case EventPlanned:
+			result.Planned++
+			result.BytesIn += event.BytesIn
+			result.BytesOut += event.BytesOut
+		case EventVerified:
+			result.Verified++
+			result.BytesIn += event.BytesIn
+		case EventSkipped:
+			result.Skipped++
+		case EventUpToDate:
+			result.UpToDate++
+		case EventSalvaged:
+			result.Salvaged++
+			result.BytesIn += event.BytesIn
+			result.BytesOut += event.BytesOut
+		case EventIgnored:
+			result.Ignored++
+		case EventFailed:
+			result.Failed++
+			err = event.Err
+		}
+		p.logEvent(event)
+		if p.options.OnEvent != nil {
+			p.options.OnEvent(event)
+		}
+	}
+	return err
+}
+
+// processFile converts a single file and describes the outcome.
+func (p *Processor) processFile(task fileTask, budget *memoryBudget) []Event {
+	filePath := task.path
+	if p.options.Mode == ModeVerify {
+		return p.verifyFile(filePath, budget)
+	}
+
+	isCompression, selected := p.selects(filePath)
+	if !selected {
+		return []Event{{Kind: EventIgnored, Path: filePath}}
+	}
+
+	target := p.outputName(task, isCompression)
+	if isCompression && p.options.Incremental && upToDate(filePath, target, budget) {
+		return []Event{{Kind: EventUpToDate, Path: filePath, Output: target}}
+	}
+
+	if p.options.DryRun {
+		return p.planFile(task, target, isCompression)
+	}
+
+	newName, skip, err := p.resolveConflict(filePath, target)
+	if err != nil {
+		return []Event{{Kind: EventFailed, Path: filePath, Op: "conflict", Err: err}}
+	}
+	if skip {
+		return []Event{{Kind: EventSkipped, Path: filePath, Output: newName}}
+	}
+
+	cost := estimateMemory(filePath, isCompression)
+	budget.acquire(cost)
+	defer budget.release(cost)
+
+	meta := defaultMeta
+	if !p.options.NoPreserve {
+		info, err := os.Stat(filePath)
+		if err != nil {
+			return []Event{{Kind: EventFailed, Path: filePath, Op: "read", Err: err}}
+		}
+		meta = sourceMeta(info)
+	}
+
+	fileData, err := os.ReadFile(filePath)
+	if err != nil {
+		return []Event{{Kind: EventFailed, Path: filePath, Op: "read", Err: err}}
+	}
+
+	var processedBlock []byte
+	if isCompression {
+		processedBlock, err = dvpl_logic.CompressDVPLWithOptions(fileData, p.options.Compress)
+	} else {
+		processedBlock, err = dvpl_logic.DecompressDVPLWithOptions(fileData, p.options.Decompress)
+	}
+
+	var salvageErr *dvpl_logic.SalvageError
+	if errors.As(err, &salvageErr) {
+		return p.writeSalvaged(filePath, newName, processedBlock, salvageErr, meta)
+	}
+
+	if err != nil {
+		return []Event{{Kind: EventFailed, Path: filePath, Op: "convert", Err: err}}
+	}
+
+	if p.options.OutputDir != "" {
+		err = os.MkdirAll(filepath.Dir(newName), 0755)
+	}
+	if err == nil {
+		err = writeFileAtomic(newName, processedBlock, meta)
+	}
+	if err != nil {
+		return []Event{{Kind: EventFailed, Path: filePath, Output: newName, Op: "write", Err: err}}
+	}
+
+	removeOriginal := !p.options.KeepOriginals && p.options.OutputDir == ""
+
+	// The original is only removed once the output is known to be good.
+	if removeOriginal {
+		if err := checkWritten(newName, fileData, processedBlock, isCompression); err != nil {
+			os.Remove(newName)
+			return []Event{{Kind: EventFailed, Path: filePath, Output: newName, Op: "check", Err: err}}
+		}
+	}
+
+	events := []Event{{
+		Kind:      EventConverted,
+		Path:      filePath,
+		Output:    newName,
+		StoredRaw: isCompression && dvpl_logic.IsStored(processedBlock),
+		BytesIn:   int64(len(fileData)),
+		BytesOut:  int64(len(processedBlock)),
+	}}
+
+	if removeOriginal {
+		err := os.Remove(filePath)
+		if err != nil {
+			events = append(events, Event{Kind: EventRemoveFailed, Path: filePath, Op: "remove", Err: err})
+		}
+	}
+
+	return events
+}
+
+// selects reports whether the mode converts filePath and in which direction.
+func (p *Processor) selects(filePath string) (isCompression, selected bool) {
+	isDVPL := strings.HasSuffix(filePath, dvplExtension)
+	switch p.options.Mode {
+	case ModeCompress:
+		return true, !isDVPL
+	case ModeDecompress:
+		return false, isDVPL
+	}
+	return false, false
+}
+
+// planFile describes the conversion of task without performing it. The
+// decompressed size is read from the footer; compressed sizes are unknown
+// until the data is compressed.
+func (p *Processor) planFile(task fileTask, target string, isCompression bool) []Event {
+	filePath := task.path
+
+	newName, skip, err := p.resolveConflict(filePath, target)
+	if err != nil {
+		return []Event{{Kind: EventFailed, Path: filePath, Output: target, Op: "conflict", Err: err}}
+	}
+	if skip {
+		return []Event{{Kind: EventSkipped, Path: filePath, Output: newName}}
+	}
+
+	file, err := os.Open(filePath)
+	if err != nil {
+		return []Event{{Kind: EventFailed, Path: filePath, Op: "read", Err: err}}
+	}
+	defer file.Close()
+
+	info, err := file.Stat()
+	if err != nil {
+		return []Event{{Kind: EventFailed, Path: filePath, Op: "read", Err: err}}
+	}
+
+	event := Event{
+		Kind:           EventPlanned,
+		Path:           filePath,
+		Output:         newName,
+		BytesIn:        info.Size(),
+		RemoveOriginal: !p.options.KeepOriginals && p.options.OutputDir == "",
+	}
+
+	if !isCompression {
+		footer, err := dvpl_logic.ReadFooter(file, info.Size())
+		if err != nil {
+			return []Event{{Kind: EventFailed, Path: filePath, Op: "convert", Err: err}}
+		}
+		event.BytesOut = int64(footer.OriginalSize)
+	}
+
+	if _, err := os.Stat(newName); err == nil {
+		event.Overwrite = true
+	}
+	return []Event{event}
+}
+
+// outputName returns where the conversion of task is written: next to the
+// source, or at the same relative path under OutputDir.
+func (p *Processor) outputName(task fileTask, isCompression bool) string {
+	name := task.path
+	if p.options.OutputDir != "" {
+		name = filepath.Join(p.options.OutputDir, task.rel)
+	}
+	if isCompression {
+		return name + dvplExtension
+	}
+	return strings.TrimSuffix(name, dvplExtension)
+}
+
+// verifyFile checks the footer, sizes and checksum of a DVPL file and decodes
+// its payload, discarding the result.
+func (p *Processor) verifyFile(filePath string, budget *memoryBudget) []Event {
+	if !strings.HasSuffix(filePath, dvplExtension) {
+		return []Event{{Kind: EventIgnored, Path: filePath}}
+	}
+
+	cost := estimateMemory(filePath, false)
+	budget.acquire(cost)
+	defer budget.release(cost)
+
+	file, err := os.Open(filePath)
+	if err != nil {
+		return []Event{{Kind: EventFailed, Path: filePath, Op: "read", Err: err}}
+	}
+	defer file.Close()
+
+	info, err := file.Stat()
+	if err != nil {
+		return []Event{{Kind: EventFailed, Path: filePath, Op: "read", Err: err}}
+	}
+
+	reader, err := dvpl_logic.NewReaderWithOptions(file, info.Size(), p.options.Decompress)
+	if err == nil {
+		_, err = io.Copy(io.Discard, reader)
+	}
+	if err != nil {
+		return []Event{{Kind: EventFailed, Path: filePath, Op: "verify", Err: err}}
+	}
+
+	return []Event{{Kind: EventVerified, Path: filePath, BytesIn: info.Size()}}
+}
+
+// estimateMemory guesses how many bytes converting path keeps in memory: the
+// input plus the output, whose size is read from the footer when decompressing.
+func estimateMemory(path string, isCompression bool) int64 {
+	file, err := os.Open(path)
+	if err != nil {
+		return 0
+	}
+	defer file.Close()
+
+	info, err := file.Stat()
+	if err != nil {
+		return 0
+	}
+
+	if isCompression {
+		return 2 * info.Size()
+	}
+
+	footer, err := dvpl_logic.ReadFooter(file, info.Size())
+	if err != nil {
+		return info.Size()
+	}
+	return info.Size() + int64(footer.OriginalSize)
+}
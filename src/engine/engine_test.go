@@ -0,0 +1,172 @@
+package engine
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"testing"
+	"time"
+)
+
+func TestRemoveOriginals(t *testing.T) {
+	tests := []struct {
+		name       string
+		options    Options
+		outputDir  bool
+		wantSource bool
+	}{
+		{name: "default", wantSource: false},
+		{name: "keep originals", options: Options{KeepOriginals: true}, wantSource: true},
+		{name: "output directory", outputDir: true, wantSource: true},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			dir := t.TempDir()
+			writeTestFile(t, filepath.Join(dir, "in", "sub", "a.xml"), "<a/>")
+
+			options := test.options
+			options.Mode = ModeCompress
+			output := filepath.Join(dir, "in", "sub", "a.xml.dvpl")
+			if test.outputDir {
+				options.OutputDir = filepath.Join(dir, "out")
+				output = filepath.Join(dir, "out", "sub", "a.xml.dvpl")
+			}
+
+			result, err := New(options).Run(context.Background(), filepath.Join(dir, "in"))
+			if err != nil || result.Converted != 1 {
+				t.Fatalf("Run = %+v, %v, want one converted file", result, err)
+			}
+			if _, err := os.Stat(output); err != nil {
+				t.Errorf("output missing: %v", err)
+			}
+			_, err = os.Stat(filepath.Join(dir, "in", "sub", "a.xml"))
+			if gotSource := err == nil; gotSource != test.wantSource {
+				t.Errorf("source kept = %t, want %t", gotSource, test.wantSource)
+			}
+			if test.outputDir {
+				if names := walkNames(t, filepath.Join(dir, "in"), Options{}); len(names) != 1 {
+					t.Errorf("input tree = %v, want it untouched", names)
+				}
+			}
+		})
+	}
+}
+
+func TestFailedCheckKeepsSource(t *testing.T) {
+	checkFailure := errors.New("read back differs")
+	checkWritten = func(string, []byte, []byte, bool) error { return checkFailure }
+	defer func() { checkWritten = checkOutput }()
+
+	dir := t.TempDir()
+	source := filepath.Join(dir, "a.xml")
+	writeTestFile(t, source, "<a/>")
+
+	var events []Event
+	result, err := New(Options{Mode: ModeCompress, OnEvent: func(event Event) { events = append(events, event) }}).Run(context.Background(), dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result.Failed != 1 || len(events) != 1 || events[0].Op != "check" || !errors.Is(events[0].Err, checkFailure) {
+		t.Fatalf("result = %+v, events = %+v, want a failed check", result, events)
+	}
+	if data, err := os.ReadFile(source); err != nil || string(data) != "<a/>" {
+		t.Errorf("source = %q, %v, want it kept", data, err)
+	}
+	if _, err := os.Stat(source + dvplExtension); !os.IsNotExist(err) {
+		t.Errorf("bad output was kept: %v", err)
+	}
+}
+
+func TestEventOrder(t *testing.T) {
+	dir := t.TempDir()
+	var want []string
+	for i := 0; i < 64; i++ {
+		name := filepath.Join(dir, fmt.Sprintf("f%02d.xml", i))
+		writeTestFile(t, name, fmt.Sprintf("<f>%d</f>", i))
+		want = append(want, name)
+	}
+
+	var got []string
+	options := Options{Mode: ModeCompress, Jobs: 8, OnEvent: func(event Event) { got = append(got, event.Path) }}
+	if _, err := New(options).Run(context.Background(), dir); err != nil {
+		t.Fatal(err)
+	}
+	if !sort.StringsAreSorted(got) || len(got) != len(want) {
+		t.Errorf("events in order %v, want walk order %v", got, want)
+	}
+}
+
+func TestCancel(t *testing.T) {
+	dir := t.TempDir()
+	for i := 0; i < 8; i++ {
+		writeTestFile(t, filepath.Join(dir, fmt.Sprintf("f%d.xml", i)), "<f/>")
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	result, err := New(Options{Mode: ModeCompress}).Run(ctx, dir)
+	if !errors.Is(err, context.Canceled) {
+		t.Fatalf("Run = %v, want context.Canceled", err)
+	}
+	if result.Converted != 0 {
+		t.Errorf("converted %d files after cancellation", result.Converted)
+	}
+	if names := walkNames(t, dir, Options{}); len(names) != 8 {
+		t.Errorf("tree = %v, want it untouched", names)
+	}
+}
+
+func TestConflictFail(t *testing.T) {
+	dir := t.TempDir()
+	writeTestFile(t, filepath.Join(dir, "a.xml"), "<a/>")
+	writeTestFile(t, filepath.Join(dir, "b.xml"), "<b/>")
+	writeTestFile(t, filepath.Join(dir, "b.xml.dvpl"), "existing")
+
+	_, err := New(Options{Mode: ModeCompress, OnConflict: ConflictFail}).Run(context.Background(), dir)
+	if !errors.Is(err, ErrConflict) {
+		t.Fatalf("Run = %v, want ErrConflict", err)
+	}
+	want := []string{"a.xml", "b.xml", "b.xml.dvpl"}
+	if names := walkNames(t, dir, Options{}); fmt.Sprint(names) != fmt.Sprint(want) {
+		t.Errorf("tree = %v, want %v untouched", names, want)
+	}
+	if data, _ := os.ReadFile(filepath.Join(dir, "b.xml.dvpl")); string(data) != "existing" {
+		t.Errorf("existing output was overwritten")
+	}
+}
+
+func TestPreserveMetadata(t *testing.T) {
+	mtime := time.Date(2020, 1, 2, 3, 4, 5, 0, time.UTC)
+	for _, noPreserve := range []bool{false, true} {
+		dir := t.TempDir()
+		source := filepath.Join(dir, "a.xml")
+		writeTestFile(t, source, "<a/>")
+		if err := os.Chmod(source, 0600); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.Chtimes(source, mtime, mtime); err != nil {
+			t.Fatal(err)
+		}
+
+		options := Options{Mode: ModeCompress, KeepOriginals: true, NoPreserve: noPreserve}
+		if _, err := New(options).Run(context.Background(), dir); err != nil {
+			t.Fatal(err)
+		}
+		info, err := os.Stat(source + dvplExtension)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		wantPerm, preserved := os.FileMode(0600), info.ModTime().Equal(mtime)
+		if noPreserve {
+			wantPerm, preserved = 0644, !preserved
+		}
+		if info.Mode().Perm() != wantPerm || !preserved {
+			t.Errorf("NoPreserve %t: output mode %v, mtime %v", noPreserve, info.Mode().Perm(), info.ModTime())
+		}
+	}
+}
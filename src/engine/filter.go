@@ -0,0 +1,180 @@
+package engine
+
+import (
+	"bufio"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+)
+
+// IgnoreFileName is the per-directory file listing paths to leave alone, with
+// the same syntax as .gitignore.
+const IgnoreFileName = ".dvplignore"
+
+// vcsDirs are skipped like hidden directories unless IncludeHidden is set.
+var vcsDirs = map[string]bool{"CVS": true, "_darcs": true}
+
+// ignoreRule is a single pattern read from an ignore file in dir.
+type ignoreRule struct {
+	dir     string
+	pattern string
+	negate  bool
+	dirOnly bool
+}
+
+// filter selects the files a directory walk hands to the workers.
+type filter struct {
+	root          string
+	include       []string
+	exclude       []string
+	includeHidden bool
+	rules         map[string][]ignoreRule // Rules in effect inside each visited directory.
+}
+
+func newFilter(options Options, root string) *filter {
+	return &filter{
+		root:          root,
+		include:       options.Include,
+		exclude:       options.Exclude,
+		includeHidden: options.IncludeHidden,
+		rules:         make(map[string][]ignoreRule),
+	}
+}
+
+// skipDir reports whether the walk should not descend into dir. Directories
+// that are entered have their ignore file loaded.
+func (f *filter) skipDir(dir string, d fs.DirEntry) bool {
+	if dir != f.root {
+		name := d.Name()
+		if !f.includeHidden && (strings.HasPrefix(name, ".") || vcsDirs[name]) {
+			return true
+		}
+		if f.excluded(dir, true) {
+			return true
+		}
+	}
+
+	rules := f.rules[filepath.Dir(dir)]
+	if dir == f.root {
+		rules = nil
+	}
+	f.rules[dir] = append(rules[:len(rules):len(rules)], readIgnoreFile(dir)...)
+	return false
+}
+
+// skipFile reports whether file is filtered out.
+func (f *filter) skipFile(file string) bool {
+	if filepath.Base(file) == IgnoreFileName || f.excluded(file, false) {
+		return true
+	}
+	if len(f.include) == 0 {
+		return false
+	}
+	rel := f.rel(file)
+	for _, pattern := range f.include {
+		if matchPattern(pattern, rel) {
+			return false
+		}
+	}
+	return true
+}
+
+// excluded applies the -exclude patterns and the ignore files of the parent
+// directories to name.
+func (f *filter) excluded(name string, isDir bool) bool {
+	rel := f.rel(name)
+	for _, pattern := range f.exclude {
+		if matchPattern(pattern, rel) {
+			return true
+		}
+	}
+
+	// As in .gitignore, the last matching rule wins.
+	ignored := false
+	for _, rule := range f.rules[filepath.Dir(name)] {
+		if rule.dirOnly && !isDir {
+			continue
+		}
+		ruleRel, err := filepath.Rel(rule.dir, name)
+		if err != nil {
+			continue
+		}
+		if matchPattern(rule.pattern, filepath.ToSlash(ruleRel)) {
+			ignored = !rule.negate
+		}
+	}
+	return ignored
+}
+
+func (f *filter) rel(name string) string {
+	rel, err := filepath.Rel(f.root, name)
+	if err != nil {
+		return filepath.ToSlash(name)
+	}
+	return filepath.ToSlash(rel)
+}
+
+// readIgnoreFile parses the ignore file of dir, if there is one.
+func readIgnoreFile(dir string) []ignoreRule {
+	file, err := os.Open(filepath.Join(dir, IgnoreFileName))
+	if err != nil {
+		return nil
+	}
+	defer file.Close()
+
+	var rules []ignoreRule
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		rule := ignoreRule{dir: dir}
+		if strings.HasPrefix(line, "!") {
+			rule.negate = true
+			line = line[1:]
+		}
+		if strings.HasSuffix(line, "/") {
+			rule.dirOnly = true
+			line = strings.TrimSuffix(line, "/")
+		}
+		rule.pattern = line
+		rules = append(rules, rule)
+	}
+	return rules
+}
+
+// matchPattern matches a slash separated relative name against a glob pattern
+// in which "**" stands for any number of path segments. A pattern without a
+// slash matches the base name at any depth, a leading slash anchors it to the
+// top of the tree.
+func matchPattern(pattern, name string) bool {
+	if strings.HasPrefix(pattern, "/") {
+		pattern = pattern[1:]
+	} else if !strings.Contains(pattern, "/") {
+		pattern = "**/" + pattern
+	}
+	return matchSegments(strings.Split(pattern, "/"), strings.Split(name, "/"))
+}
+
+func matchSegments(pattern, name []string) bool {
+	if len(pattern) == 0 {
+		return len(name) == 0
+	}
+	if pattern[0] == "**" {
+		for i := 0; i <= len(name); i++ {
+			if matchSegments(pattern[1:], name[i:]) {
+				return true
+			}
+		}
+		return false
+	}
+	if len(name) == 0 {
+		return false
+	}
+	matched, err := path.Match(pattern[0], name[0])
+	return err == nil && matched && matchSegments(pattern[1:], name[1:])
+}
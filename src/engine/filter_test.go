@@ -0,0 +1,156 @@
+package engine
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"testing"
+)
+
+func TestMatchPattern(t *testing.T) {
+	tests := []struct {
+		pattern string
+		name    string
+		want    bool
+	}{
+		// A pattern without a slash matches the base name at any depth.
+		{"*.xml", "a.xml", true},
+		{"*.xml", "maps/a.xml", true},
+		{"*.xml", "a.yaml", false},
+		{"docs", "a/docs", true},
+
+		// "**" matches any number of segments, including none.
+		{"**/*.xml", "a.xml", true},
+		{"**/*.xml", "a/b/c.xml", true},
+		{"maps/**", "maps/a/b.xml", true},
+		{"maps/**", "other/a.xml", false},
+		{"a/**/b.xml", "a/b.xml", true},
+		{"a/**/b.xml", "a/x/y/b.xml", true},
+		{"a/**/b.xml", "x/a/b.xml", false},
+
+		// A pattern with a slash, or a leading one, is anchored to the top.
+		{"/a.xml", "a.xml", true},
+		{"/a.xml", "sub/a.xml", false},
+		{"maps/*.xml", "maps/a.xml", true},
+		{"maps/*.xml", "x/maps/a.xml", false},
+		{"maps/*.xml", "maps/sub/a.xml", false},
+
+		// Globs do not cross segments.
+		{"a*", "ab/c", false},
+		{"[", "[", false},
+	}
+	for _, test := range tests {
+		if got := matchPattern(test.pattern, test.name); got != test.want {
+			t.Errorf("matchPattern(%q, %q) = %t, want %t", test.pattern, test.name, got, test.want)
+		}
+	}
+}
+
+func TestFilter(t *testing.T) {
+	tests := []struct {
+		name    string
+		ignore  map[string]string // Ignore files by directory.
+		options Options
+		want    []string
+	}{
+		{
+			name: "hidden and VCS directories",
+			want: []string{"a.xml", "b.yaml", "docs/readme.txt", "maps/keep.xml", "maps/sub/c.xml"},
+		},
+		{
+			name:    "include hidden",
+			options: Options{IncludeHidden: true},
+			want:    []string{".git/HEAD", "CVS/entries", "a.xml", "b.yaml", "docs/readme.txt", "maps/keep.xml", "maps/sub/c.xml"},
+		},
+		{
+			name:   "directory-only rule",
+			ignore: map[string]string{".": "docs/\nsub/\nb.yaml/\n"},
+			want:   []string{"a.xml", "b.yaml", "maps/keep.xml"},
+		},
+		{
+			name:   "negation, last rule wins",
+			ignore: map[string]string{".": "*.xml\n!keep.xml\n"},
+			want:   []string{"b.yaml", "docs/readme.txt", "maps/keep.xml"},
+		},
+		{
+			name:   "negation in a nested ignore file",
+			ignore: map[string]string{".": "*.xml\n", "maps": "!c.xml\n"},
+			want:   []string{"b.yaml", "docs/readme.txt", "maps/sub/c.xml"},
+		},
+		{
+			name:   "anchored rule and comments",
+			ignore: map[string]string{".": "# comment\n/a.xml\n", "maps": "/sub\n"},
+			want:   []string{"b.yaml", "docs/readme.txt", "maps/keep.xml"},
+		},
+		{
+			name:    "include",
+			options: Options{Include: []string{"**/*.xml"}},
+			want:    []string{"a.xml", "maps/keep.xml", "maps/sub/c.xml"},
+		},
+		{
+			name:    "exclude wins over include",
+			options: Options{Include: []string{"*.xml"}, Exclude: []string{"maps/sub/**", "a.xml"}},
+			want:    []string{"maps/keep.xml"},
+		},
+		{
+			name:    "exclude directory",
+			options: Options{Exclude: []string{"maps"}},
+			want:    []string{"a.xml", "b.yaml", "docs/readme.txt"},
+		},
+		{
+			name:    "ignore file wins over include",
+			ignore:  map[string]string{".": "keep.xml\n"},
+			options: Options{Include: []string{"**/*.xml"}},
+			want:    []string{"a.xml", "maps/sub/c.xml"},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			root := t.TempDir()
+			for _, name := range []string{"a.xml", "b.yaml", "docs/readme.txt", "maps/keep.xml", "maps/sub/c.xml", ".git/HEAD", "CVS/entries"} {
+				writeTestFile(t, filepath.Join(root, name), "")
+			}
+			for dir, rules := range test.ignore {
+				writeTestFile(t, filepath.Join(root, dir, IgnoreFileName), rules)
+			}
+
+			if got := walkNames(t, root, test.options); !reflect.DeepEqual(got, test.want) {
+				t.Errorf("walk = %v, want %v", got, test.want)
+			}
+		})
+	}
+}
+
+func writeTestFile(t *testing.T, name, data string) {
+	t.Helper()
+	if err := os.MkdirAll(filepath.Dir(name), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(name, []byte(data), 0644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// walkNames returns the sorted slash separated names a walk of root selects.
+func walkNames(t *testing.T, root string, options Options) []string {
+	t.Helper()
+	info, err := os.Stat(root)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var names []string
+	err = New(options).walkFiles(context.Background(), root, info, func(task fileTask) {
+		names = append(names, filepath.ToSlash(task.rel))
+	}, func(path string, err error) {
+		t.Errorf("walk error at %s: %v", path, err)
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	sort.Strings(names)
+	return names
+}
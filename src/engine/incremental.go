@@ -0,0 +1,63 @@
+package engine
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"io"
+	"os"
+
+	"github.com/rifsxd/dvpl_go/dvpl_logic"
+)
+
+// upToDate reports whether the DVPL file target was built from the current
+// contents of source: it must not be older than source, its footer must
+// record the size of source and it must decompress to the same data.
+func upToDate(source, target string, budget *memoryBudget) bool {
+	sourceInfo, err := os.Stat(source)
+	if err != nil {
+		return false
+	}
+	targetFile, err := os.Open(target)
+	if err != nil {
+		return false
+	}
+	defer targetFile.Close()
+
+	targetInfo, err := targetFile.Stat()
+	if err != nil || targetInfo.ModTime().Before(sourceInfo.ModTime()) {
+		return false
+	}
+
+	reader, err := dvpl_logic.NewReader(targetFile, targetInfo.Size())
+	if err != nil || int64(reader.Footer().OriginalSize) != sourceInfo.Size() {
+		return false
+	}
+
+	cost := targetInfo.Size() + sourceInfo.Size()
+	budget.acquire(cost)
+	defer budget.release(cost)
+
+	targetHash := sha256.New()
+	if _, err := io.Copy(targetHash, reader); err != nil {
+		return false
+	}
+	sourceHash, err := hashFile(source)
+	if err != nil {
+		return false
+	}
+	return bytes.Equal(targetHash.Sum(nil), sourceHash)
+}
+
+func hashFile(name string) ([]byte, error) {
+	file, err := os.Open(name)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	hash := sha256.New()
+	if _, err := io.Copy(hash, file); err != nil {
+		return nil, err
+	}
+	return hash.Sum(nil), nil
+}
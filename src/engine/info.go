@@ -0,0 +1,76 @@
+package engine
+
+import (
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/rifsxd/dvpl_go/dvpl_logic"
+)
+
+// FileInfo describes the footer of a single DVPL file.
+type FileInfo struct {
+	Path           string  `json:"path"`
+	OriginalSize   uint32  `json:"originalSize"`
+	CompressedSize uint32  `json:"compressedSize"`
+	Ratio          float64 `json:"ratio"`
+	CRC32          uint32  `json:"crc32"`
+	Type           string  `json:"type"`
+	CRCValid       bool    `json:"crcValid"`
+	Error          string  `json:"error,omitempty"`
+}
+
+// Inspect reads the footer and checks the payload checksum of every DVPL file
+// under directoryOrFile, without decompressing anything.
+func Inspect(directoryOrFile string) ([]FileInfo, error) {
+	var infos []FileInfo
+	err := filepath.WalkDir(directoryOrFile, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if d.IsDir() || !strings.HasSuffix(path, dvplExtension) {
+			return nil
+		}
+		infos = append(infos, InspectFile(path))
+		return nil
+	})
+	return infos, err
+}
+
+// InspectFile reads the footer of a DVPL file and checks its payload checksum.
+func InspectFile(path string) FileInfo {
+	info := FileInfo{Path: path}
+
+	file, err := os.Open(path)
+	if err != nil {
+		info.Error = err.Error()
+		return info
+	}
+	defer file.Close()
+
+	stat, err := file.Stat()
+	if err != nil {
+		info.Error = err.Error()
+		return info
+	}
+
+	footer, err := dvpl_logic.ReadFooter(file, stat.Size())
+	if err != nil {
+		info.Error = err.Error()
+		return info
+	}
+
+	info.OriginalSize = footer.OriginalSize
+	info.CompressedSize = footer.CompressedSize
+	info.Ratio = footer.Ratio()
+	info.CRC32 = footer.CRC32
+	info.Type = footer.TypeName()
+
+	if err := dvpl_logic.VerifyCRC32(file, stat.Size(), footer); err != nil {
+		info.Error = err.Error()
+	} else {
+		info.CRCValid = true
+	}
+	return info
+}
@@ -0,0 +1,65 @@
+package engine
+
+import (
+	"context"
+	"log/slog"
+)
+
+// logEvent reports event to the configured Logger.
+func (p *Processor) logEvent(event Event) {
+	logger := p.options.Logger
+	switch event.Kind {
+	case EventConverted:
+		logger.Info(p.options.Mode+"ed", "path", event.Path, "output", event.Output,
+			"bytes_in", event.BytesIn, "bytes_out", event.BytesOut, "stored_raw", event.StoredRaw)
+	case EventVerified:
+		logger.Info("valid", "path", event.Path, "bytes", event.BytesIn)
+	case EventPlanned:
+		logger.Debug("planned", "path", event.Path, "output", event.Output, "overwrite", event.Overwrite)
+	case EventSkipped:
+		logger.Info("skipped, output exists", "path", event.Path, "output", event.Output)
+	case EventUpToDate:
+		logger.Info("up to date", "path", event.Path, "output", event.Output)
+	case EventIgnored:
+		logger.Debug("ignored", "path", event.Path)
+	case EventSalvaged:
+		logger.Warn("salvaged", "path", event.Path, "output", event.Output, "err", event.Err)
+	case EventRemoveFailed:
+		logger.Warn("original not removed", "path", event.Path, "err", event.Err)
+	case EventFailed:
+		attrs := []any{"path", event.Path, "op", event.Op, "err", event.Err}
+		if event.Output != "" {
+			attrs = append(attrs, "output", event.Output)
+		}
+		logger.Error(failureMessage(event.Op), attrs...)
+	}
+}
+
+// failureMessage describes a failed operation.
+func failureMessage(op string) string {
+	switch op {
+	case "walk":
+		return "cannot read directory"
+	case "read":
+		return "cannot read file"
+	case "write":
+		return "cannot write file"
+	case "check":
+		return "written file is damaged, original kept"
+	case "verify":
+		return "verification failed"
+	case "conflict":
+		return "output conflict"
+	case "remove":
+		return "original not removed"
+	}
+	return "conversion failed"
+}
+
+// discardHandler drops every record, for runs without a Logger.
+type discardHandler struct{}
+
+func (discardHandler) Enabled(context.Context, slog.Level) bool  { return false }
+func (discardHandler) Handle(context.Context, slog.Record) error { return nil }
+func (h discardHandler) WithAttrs([]slog.Attr) slog.Handler      { return h }
+func (h discardHandler) WithGroup(string) slog.Handler           { return h }
@@ -0,0 +1,253 @@
+package engine
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"hash/crc32"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/rifsxd/dvpl_go/dvpl_logic"
+)
+
+// ManifestVersion is the version of the manifest format written by
+// BuildManifest.
+const ManifestVersion = 1
+
+// Manifest lists every file of a tree with the checksums of its content.
+type Manifest struct {
+	Version int             `json:"version"`
+	Files   []ManifestEntry `json:"files"`
+}
+
+// ManifestEntry describes a single file of a Manifest. The footer fields are
+// only set for DVPL files, and SHA256 is always taken over the decompressed
+// content.
+type ManifestEntry struct {
+	Path           string `json:"path"` // Relative to the root, separated by '/'.
+	Size           int64  `json:"size"`
+	OriginalSize   uint32 `json:"originalSize,omitempty"`
+	CompressedSize uint32 `json:"compressedSize,omitempty"`
+	Type           string `json:"type,omitempty"`
+	CRC32          uint32 `json:"crc32"` // Footer CRC32 of DVPL files, CRC32 of the content otherwise.
+	SHA256         string `json:"sha256"`
+}
+
+// ManifestProblem names a file of a tree that could not be checked.
+type ManifestProblem struct {
+	Path  string `json:"path"`
+	Error string `json:"error"`
+}
+
+// ManifestCheck is the result of comparing a tree with a Manifest.
+type ManifestCheck struct {
+	Checked   int               `json:"checked"`
+	Added     []string          `json:"added"`     // Files missing from the manifest.
+	Missing   []string          `json:"missing"`   // Files missing from the tree.
+	Modified  []string          `json:"modified"`  // Files whose content or footer changed.
+	Corrupted []ManifestProblem `json:"corrupted"` // Files that cannot be read or decompressed.
+}
+
+// OK reports whether the tree matched the manifest.
+func (c *ManifestCheck) OK() bool {
+	return len(c.Added)+len(c.Missing)+len(c.Modified)+len(c.Corrupted) == 0
+}
+
+// ManifestOptions selects the files of a manifest.
+type ManifestOptions struct {
+	// Include, Exclude and IncludeHidden filter the walk as in Options.
+	Include       []string
+	Exclude       []string
+	IncludeHidden bool
+
+	// Skip lists files left out, such as the manifest itself. They are
+	// compared by absolute path, so they need not exist yet.
+	Skip []string
+
+	// SkipFiles lists open files left out, compared with os.SameFile, such as
+	// standard output redirected into the tree.
+	SkipFiles []fs.FileInfo
+}
+
+// BuildManifest describes every file under directoryOrFile selected by
+// options. DVPL files are decompressed to hash their content and fail the
+// build if they are damaged.
+func BuildManifest(directoryOrFile string, options ManifestOptions) (*Manifest, error) {
+	manifest := &Manifest{Version: ManifestVersion, Files: []ManifestEntry{}}
+	err := walkManifest(directoryOrFile, options, func(path, rel string) error {
+		entry, err := describeFile(path, rel)
+		if err != nil {
+			return fmt.Errorf("%s: %w", path, err)
+		}
+		manifest.Files = append(manifest.Files, entry)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return manifest, nil
+}
+
+// CheckManifest compares the files under directoryOrFile selected by options
+// with manifest.
+func CheckManifest(directoryOrFile string, manifest *Manifest, options ManifestOptions) (*ManifestCheck, error) {
+	check := &ManifestCheck{Added: []string{}, Missing: []string{}, Modified: []string{}, Corrupted: []ManifestProblem{}}
+
+	expected := make(map[string]ManifestEntry, len(manifest.Files))
+	for _, entry := range manifest.Files {
+		expected[entry.Path] = entry
+	}
+
+	err := walkManifest(directoryOrFile, options, func(path, rel string) error {
+		want, ok := expected[rel]
+		if !ok {
+			check.Added = append(check.Added, rel)
+			return nil
+		}
+		delete(expected, rel)
+		check.Checked++
+
+		got, err := describeFile(path, rel)
+		if err != nil {
+			check.Corrupted = append(check.Corrupted, ManifestProblem{Path: rel, Error: err.Error()})
+		} else if got != want {
+			check.Modified = append(check.Modified, rel)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	// Keep the order of the manifest for the files that are gone.
+	for _, entry := range manifest.Files {
+		if _, ok := expected[entry.Path]; ok {
+			check.Missing = append(check.Missing, entry.Path)
+		}
+	}
+	return check, nil
+}
+
+// ReadManifest decodes a manifest written as JSON.
+func ReadManifest(r io.Reader) (*Manifest, error) {
+	var manifest Manifest
+	if err := json.NewDecoder(r).Decode(&manifest); err != nil {
+		return nil, fmt.Errorf("invalid manifest: %w", err)
+	}
+	if manifest.Version != ManifestVersion {
+		return nil, fmt.Errorf("unsupported manifest version %d", manifest.Version)
+	}
+	return &manifest, nil
+}
+
+// WriteSHA256Sums writes the manifest in the format of sha256sum. DVPL files
+// are listed under their decompressed names, so 'sha256sum -c' checks a
+// decompressed copy of the tree.
+func (m *Manifest) WriteSHA256Sums(w io.Writer) error {
+	for _, entry := range m.Files {
+		name := entry.Path
+		if entry.Type != "" {
+			name = strings.TrimSuffix(name, dvplExtension)
+		}
+		if _, err := fmt.Fprintf(w, "%s  %s\n", entry.SHA256, name); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// walkManifest calls visit with every file under directoryOrFile selected by
+// options and its manifest path, in lexical order.
+func walkManifest(directoryOrFile string, options ManifestOptions, visit func(path, rel string) error) error {
+	info, err := os.Stat(directoryOrFile)
+	if err != nil {
+		return err
+	}
+
+	skip := make(map[string]bool, len(options.Skip))
+	for _, name := range options.Skip {
+		if abs, err := filepath.Abs(name); err == nil {
+			skip[abs] = true
+		}
+	}
+
+	filter := newFilter(Options{Include: options.Include, Exclude: options.Exclude, IncludeHidden: options.IncludeHidden}, directoryOrFile)
+	return filepath.WalkDir(directoryOrFile, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if d.IsDir() {
+			if filter.skipDir(path, d) {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if info.IsDir() && filter.skipFile(path) {
+			return nil
+		}
+		if abs, err := filepath.Abs(path); err == nil && skip[abs] {
+			return nil
+		}
+		if len(options.SkipFiles) > 0 {
+			if fileInfo, err := d.Info(); err == nil {
+				for _, skipped := range options.SkipFiles {
+					if os.SameFile(fileInfo, skipped) {
+						return nil
+					}
+				}
+			}
+		}
+
+		rel := filepath.Base(path)
+		if info.IsDir() {
+			rel, _ = filepath.Rel(directoryOrFile, path)
+		}
+		return visit(path, filepath.ToSlash(rel))
+	})
+}
+
+// describeFile computes the manifest entry of the file path.
+func describeFile(path, rel string) (ManifestEntry, error) {
+	entry := ManifestEntry{Path: rel}
+
+	file, err := os.Open(path)
+	if err != nil {
+		return entry, err
+	}
+	defer file.Close()
+
+	stat, err := file.Stat()
+	if err != nil {
+		return entry, err
+	}
+	entry.Size = stat.Size()
+
+	hash := sha256.New()
+	if strings.HasSuffix(path, dvplExtension) {
+		reader, err := dvpl_logic.NewReader(file, stat.Size())
+		if err != nil {
+			return entry, err
+		}
+		footer := reader.Footer()
+		entry.OriginalSize = footer.OriginalSize
+		entry.CompressedSize = footer.CompressedSize
+		entry.Type = footer.TypeName()
+		entry.CRC32 = footer.CRC32
+		if _, err := io.Copy(hash, reader); err != nil {
+			return entry, err
+		}
+	} else {
+		checksum := crc32.NewIEEE()
+		if _, err := io.Copy(io.MultiWriter(hash, checksum), file); err != nil {
+			return entry, err
+		}
+		entry.CRC32 = checksum.Sum32()
+	}
+	entry.SHA256 = hex.EncodeToString(hash.Sum(nil))
+	return entry, nil
+}
@@ -0,0 +1,39 @@
+package engine
+
+import (
+	"io/fs"
+	"os"
+	"time"
+)
+
+// fileMeta is the metadata given to an output file.
+type fileMeta struct {
+	perm  os.FileMode
+	atime time.Time // Zero to leave the times as written.
+	mtime time.Time
+}
+
+// defaultMeta is used when the source's metadata is not preserved.
+var defaultMeta = fileMeta{perm: 0644}
+
+// sourceMeta records the permission bits and times of a source file before it
+// is converted, so the output carries the source's mtime and a round trip
+// through decompress and compress keeps timestamps stable.
+func sourceMeta(info fs.FileInfo) fileMeta {
+	return fileMeta{
+		perm:  info.Mode().Perm(),
+		atime: accessTime(info),
+		mtime: info.ModTime(),
+	}
+}
+
+// apply sets the metadata on name.
+func (m fileMeta) apply(name string) error {
+	if err := os.Chmod(name, m.perm); err != nil {
+		return err
+	}
+	if m.mtime.IsZero() {
+		return nil
+	}
+	return os.Chtimes(name, m.atime, m.mtime)
+}
@@ -0,0 +1,70 @@
+package engine
+
+import (
+	"bytes"
+	"fmt"
+	"hash/crc32"
+	"os"
+	"path/filepath"
+
+	"github.com/rifsxd/dvpl_go/dvpl_logic"
+)
+
+// writeFileAtomic writes data to a temporary file next to name, gives it the
+// metadata meta and renames it into place, so name is either left untouched
+// or fully written.
+func writeFileAtomic(name string, data []byte, meta fileMeta) error {
+	tempFile, err := os.CreateTemp(filepath.Dir(name), "."+filepath.Base(name)+".tmp-*")
+	if err != nil {
+		return err
+	}
+	tempName := tempFile.Name()
+
+	_, err = tempFile.Write(data)
+	if err == nil {
+		err = tempFile.Sync()
+	}
+	if closeErr := tempFile.Close(); err == nil {
+		err = closeErr
+	}
+	if err == nil {
+		err = meta.apply(tempName)
+	}
+	if err == nil {
+		err = os.Rename(tempName, name)
+	}
+	if err != nil {
+		os.Remove(tempName)
+	}
+	return err
+}
+
+// checkWritten checks an output before its source is removed. Tests replace
+// it to simulate a bad write.
+var checkWritten = checkOutput
+
+// checkOutput reads back a written file and makes sure it holds what was
+// meant: a compressed file must decompress to source, a decompressed file
+// must match the checksum of the decoded data.
+func checkOutput(name string, source, written []byte, isCompression bool) error {
+	readBack, err := os.ReadFile(name)
+	if err != nil {
+		return err
+	}
+
+	if isCompression {
+		roundTrip, err := dvpl_logic.DecompressDVPL(readBack)
+		if err != nil {
+			return fmt.Errorf("written file does not decompress: %w", err)
+		}
+		if !bytes.Equal(roundTrip, source) {
+			return fmt.Errorf("written file does not decompress to the original data")
+		}
+		return nil
+	}
+
+	if len(readBack) != len(written) || crc32.ChecksumIEEE(readBack) != crc32.ChecksumIEEE(written) {
+		return fmt.Errorf("written file does not match the decompressed data")
+	}
+	return nil
+}
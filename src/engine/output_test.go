@@ -0,0 +1,83 @@
+package engine
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/rifsxd/dvpl_go/dvpl_logic"
+)
+
+func TestWriteFileAtomic(t *testing.T) {
+	dir := t.TempDir()
+	name := filepath.Join(dir, "a.xml")
+	writeTestFile(t, name, "old")
+
+	mtime := time.Date(2020, 1, 2, 3, 4, 5, 0, time.UTC)
+	if err := writeFileAtomic(name, []byte("new"), fileMeta{perm: 0600, atime: mtime, mtime: mtime}); err != nil {
+		t.Fatal(err)
+	}
+	info, err := os.Stat(name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if data, _ := os.ReadFile(name); string(data) != "new" {
+		t.Errorf("contents = %q, want %q", data, "new")
+	}
+	if info.Mode().Perm() != 0600 || !info.ModTime().Equal(mtime) {
+		t.Errorf("mode %v, mtime %v, want 0600 and %v", info.Mode().Perm(), info.ModTime(), mtime)
+	}
+
+	if err := writeFileAtomic(filepath.Join(dir, "missing", "b.xml"), []byte("b"), defaultMeta); err == nil {
+		t.Error("writing into a missing directory succeeded")
+	}
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 1 {
+		t.Errorf("directory holds %d entries, want only a.xml and no temporary file", len(entries))
+	}
+}
+
+func TestCheckOutput(t *testing.T) {
+	source := []byte(strings.Repeat("<a>1</a>", 64))
+	compressed, err := dvpl_logic.CompressDVPL(source)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name          string
+		onDisk        []byte
+		written       []byte
+		isCompression bool
+		wantErr       bool
+	}{
+		{"compressed", compressed, compressed, true, false},
+		{"compressed, damaged on disk", compressed[:len(compressed)-1], compressed, true, true},
+		{"compressed, other data", mustCompress(t, []byte("other")), compressed, true, true},
+		{"decompressed", source, source, false, false},
+		{"decompressed, truncated on disk", source[:10], source, false, true},
+		{"decompressed, changed on disk", append([]byte("x"), source[1:]...), source, false, true},
+	}
+	for _, test := range tests {
+		name := filepath.Join(t.TempDir(), "out")
+		writeTestFile(t, name, string(test.onDisk))
+		err := checkOutput(name, source, test.written, test.isCompression)
+		if (err != nil) != test.wantErr {
+			t.Errorf("%s: checkOutput = %v, want error %t", test.name, err, test.wantErr)
+		}
+	}
+}
+
+func mustCompress(t *testing.T, data []byte) []byte {
+	t.Helper()
+	compressed, err := dvpl_logic.CompressDVPL(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return compressed
+}
@@ -0,0 +1,80 @@
+package engine
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+
+	"github.com/rifsxd/dvpl_go/dvpl_logic"
+)
+
+// PartialSuffix is appended to the output name of a salvaged file. Its
+// diagnostic report is written next to it with a further ".json" suffix.
+const PartialSuffix = ".partial"
+
+// salvageReport is the diagnostic report written next to a salvaged file.
+type salvageReport struct {
+	Source        string        `json:"source"`
+	Output        string        `json:"output"`
+	Footer        salvageFooter `json:"footer"`
+	FooterOffset  int64         `json:"footerOffset"`
+	TrailingBytes int64         `json:"trailingBytes"`
+	Recovered     uint64        `json:"recovered"`
+	Expected      uint64        `json:"expected"`
+	Problems      []string      `json:"problems"`
+}
+
+// salvageFooter is the footer of a salvaged file, with the keys of FileInfo.
+type salvageFooter struct {
+	OriginalSize   uint32 `json:"originalSize"`
+	CompressedSize uint32 `json:"compressedSize"`
+	CRC32          uint32 `json:"crc32"`
+	Type           string `json:"type"`
+}
+
+// writeSalvaged writes the data recovered from a damaged file to target plus
+// PartialSuffix, and its diagnostic report. The source is always kept.
+func (p *Processor) writeSalvaged(source, target string, data []byte, salvageErr *dvpl_logic.SalvageError, meta fileMeta) []Event {
+	partialName := target + PartialSuffix
+	report := salvageReport{
+		Source: source,
+		Output: partialName,
+		Footer: salvageFooter{
+			OriginalSize:   salvageErr.Footer.OriginalSize,
+			CompressedSize: salvageErr.Footer.CompressedSize,
+			CRC32:          salvageErr.Footer.CRC32,
+			Type:           salvageErr.Footer.TypeName(),
+		},
+		FooterOffset:  salvageErr.FooterOffset,
+		TrailingBytes: salvageErr.TrailingBytes,
+		Recovered:     salvageErr.Recovered,
+		Expected:      salvageErr.Expected,
+	}
+	for _, problem := range salvageErr.Problems {
+		report.Problems = append(report.Problems, problem.Error())
+	}
+	reportData, err := json.MarshalIndent(report, "", "  ")
+	if err != nil {
+		return []Event{{Kind: EventFailed, Path: source, Op: "write", Err: err}}
+	}
+
+	if p.options.OutputDir != "" {
+		err = os.MkdirAll(filepath.Dir(partialName), 0755)
+	}
+	if err == nil {
+		err = writeFileAtomic(partialName, data, meta)
+	}
+	if err == nil {
+		err = writeFileAtomic(partialName+".json", append(reportData, '\n'), defaultMeta)
+	}
+	if err != nil {
+		return []Event{{Kind: EventFailed, Path: source, Output: partialName, Op: "write", Err: err}}
+	}
+
+	fileInfo, _ := os.Stat(source)
+	event := Event{Kind: EventSalvaged, Path: source, Output: partialName, Err: salvageErr, BytesOut: int64(len(data))}
+	if fileInfo != nil {
+		event.BytesIn = fileInfo.Size()
+	}
+	return []Event{event}
+}
@@ -0,0 +1,54 @@
+package engine
+
+import (
+	"context"
+	"encoding/binary"
+	"hash/crc32"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/rifsxd/dvpl_go/dvpl_logic"
+)
+
+func TestSalvageKeepsSource(t *testing.T) {
+	// A stored file whose footer only fails the strict decode: OriginalSize
+	// disagrees with CompressedSize, everything else is consistent.
+	payload := []byte("0123456789abcdef")
+	footer := make([]byte, 20)
+	binary.LittleEndian.PutUint32(footer[0:], 4)
+	binary.LittleEndian.PutUint32(footer[4:], uint32(len(payload)))
+	binary.LittleEndian.PutUint32(footer[8:], crc32.ChecksumIEEE(payload))
+	binary.LittleEndian.PutUint32(footer[12:], dvpl_logic.TypeNone)
+	copy(footer[16:], "DVPL")
+
+	dir := t.TempDir()
+	source := filepath.Join(dir, "f.txt.dvpl")
+	writeTestFile(t, source, string(payload)+string(footer))
+
+	var events []Event
+	processor := New(Options{
+		Mode:       ModeDecompress,
+		Decompress: dvpl_logic.DecompressOptions{Salvage: true},
+		OnEvent:    func(event Event) { events = append(events, event) },
+	})
+	result, err := processor.Run(context.Background(), dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if result.Salvaged != 1 || result.Converted != 0 || len(events) != 1 || events[0].Kind != EventSalvaged {
+		t.Fatalf("result = %+v, events = %+v, want a single salvaged file", result, events)
+	}
+	if _, err := os.Stat(source); err != nil {
+		t.Errorf("source of a salvaged file was removed: %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(dir, "f.txt")); !os.IsNotExist(err) {
+		t.Errorf("salvaged file was written as a regular output: %v", err)
+	}
+	for _, name := range []string{"f.txt" + PartialSuffix, "f.txt" + PartialSuffix + ".json"} {
+		if _, err := os.Stat(filepath.Join(dir, name)); err != nil {
+			t.Errorf("missing %s: %v", name, err)
+		}
+	}
+}
@@ -0,0 +1,57 @@
+package engine
+
+import (
+	"context"
+	"io/fs"
+	"path/filepath"
+)
+
+// walkFiles visits the files selected for a run in walk order, skipping the
+// output directory and anything rejected by the filters. Errors reading a
+// directory go to walkError and do not stop the walk; cancelling ctx does.
+func (p *Processor) walkFiles(ctx context.Context, directoryOrFile string, info fs.FileInfo, visit func(fileTask), walkError func(path string, err error)) error {
+	// An output directory inside the input tree must not be walked into.
+	outputDir := ""
+	if p.options.OutputDir != "" {
+		var err error
+		outputDir, err = filepath.Abs(p.options.OutputDir)
+		if err != nil {
+			return err
+		}
+	}
+
+	filter := newFilter(p.options, directoryOrFile)
+	index := 0
+	return filepath.WalkDir(directoryOrFile, func(path string, d fs.DirEntry, err error) error {
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		if err != nil {
+			walkError(path, err)
+			return nil
+		}
+		if d.IsDir() {
+			if outputDir != "" {
+				if abs, err := filepath.Abs(path); err == nil && abs == outputDir {
+					return filepath.SkipDir
+				}
+			}
+			if filter.skipDir(path, d) {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if info.IsDir() && filter.skipFile(path) {
+			return nil
+		}
+
+		rel := filepath.Base(path)
+		if info.IsDir() {
+			rel, _ = filepath.Rel(directoryOrFile, path)
+		}
+
+		visit(fileTask{index: index, path: path, rel: rel})
+		index++
+		return nil
+	})
+}
@@ -1,10 +1,11 @@
 module github.com/rifsxd/dvpl_go
 
-go 1.20
+go 1.21
 
 require (
 	fyne.io/fyne/v2 v2.4.1
 	github.com/fatih/color v1.15.0
+	github.com/mattn/go-isatty v0.0.19
 	github.com/pierrec/lz4/v4 v4.1.18
 )
 
@@ -25,7 +26,6 @@ require (
 	github.com/jsummers/gobmp v0.0.0-20230614200233-a9de23ed2e25 // indirect
 	github.com/kr/pretty v0.3.1 // indirect
 	github.com/mattn/go-colorable v0.1.13 // indirect
-	github.com/mattn/go-isatty v0.0.19 // indirect
 	github.com/pmezard/go-difflib v1.0.0 // indirect
 	github.com/srwiley/oksvg v0.0.0-20221011165216-be6e8873101c // indirect
 	github.com/srwiley/rasterx v0.0.0-20220730225603-2ab79fcdd4ef // indirect